@@ -2,35 +2,147 @@ package jsondsl
 
 import (
 	"fmt"
-	"strconv"
 )
 
-// builtinOps lists builtin pure operations.
-var builtinOps = map[string]OpFunc{
+// builtinForms lists builtin special forms.
+var builtinForms = map[string]OpFunc{
 	"bind":   bind,
 	"lambda": lambda,
+
+	// Control flow.
+	"if":     ifOp,
+	"cond":   cond,
+	"and":    and,
+	"or":     or,
+	"switch": switchOp,
+
+	// Blocks.
+	"let": let,
+	"do":  do,
+}
+
+// builtinOps lists builtin pure operations.
+var builtinOps = map[string]StrictFunc{
+	// Arithmetic and comparison.
+	"add":   add,
+	"sub":   sub,
+	"mul":   mul,
+	"div":   div,
+	"mod":   mod,
+	"neg":   neg,
+	"abs":   abs,
+	"floor": floor,
+	"ceil":  ceil,
+	"round": round,
+	"min":   minOp,
+	"max":   maxOp,
+	"eq":    eq,
+	"ne":    ne,
+	"lt":    lt,
+	"le":    le,
+	"gt":    gt,
+	"ge":    ge,
+	"not":   not,
+
+	// Collections.
+	"map":     mapOp,
+	"filter":  filter,
+	"reduce":  reduce,
+	"range":   rangeOp,
+	"len":     length,
+	"concat":  concat,
+	"flatten": flatten,
+	"sort":    sortOp,
+	"zip":     zip,
+	"keys":    keys,
+	"values":  values,
+	"entries": entries,
+	"merge":   merge,
+	"get":     get,
+	"has":     has,
+	"set":     set,
+	"del":     del,
+
+	// Strings.
+	"format":     format,
+	"split":      split,
+	"join":       join,
+	"upper":      upper,
+	"lower":      lower,
+	"trim":       trim,
+	"replace":    replace,
+	"contains":   contains,
+	"startsWith": startsWith,
+	"substr":     substr,
+	"match":      match,
+	"findAll":    findAll,
+	"replaceAll": replaceAll,
+}
+
+// evalArgs evaluates each argument in scope.
+func evalArgs(scope *Scope, args []any) ([]any, error) {
+	vs := make([]any, len(args))
+	for i, a := range args {
+		v, err := Eval(scope, a)
+		if err != nil {
+			return nil, err
+		}
+		vs[i] = v
+	}
+	return vs, nil
+}
+
+// checkArity returns an error unless args has exactly n elements.
+func checkArity(name string, args []any, n int) error {
+	if len(args) != n {
+		return newError(KindArity, NoPos, "%s expects %d arguments: got %d", name, n, len(args))
+	}
+	return nil
+}
+
+// checkMinArity returns an error unless args has at least n elements.
+func checkMinArity(name string, args []any, n int) error {
+	if len(args) < n {
+		return newError(KindArity, NoPos, "%s expects at least %d arguments: got %d", name, n, len(args))
+	}
+	return nil
+}
+
+// checkArgsN returns an error unless args has between min and max elements.
+func checkArgsN(name string, args []any, min, max int) error {
+	if min == max {
+		return checkArity(name, args, min)
+	}
+	if len(args) < min || len(args) > max {
+		return newError(KindArity, NoPos, "%s expects %d to %d arguments: got %d", name, min, max, len(args))
+	}
+	return nil
+}
+
+// argTypeError returns an error for argument i of name having an unexpected type.
+func argTypeError(name string, i int, want string, v any) error {
+	return newError(KindType, NoPos, "%s expects %s in argument %d: found %s", name, want, i, TypeName(v))
+}
+
+// numberArg returns argument i of name as a number.
+func numberArg(name string, args []any, i int) (float64, error) {
+	x, ok := args[i].(float64)
+	if !ok {
+		return 0, argTypeError(name, i, "number", args[i])
+	}
+	return x, nil
 }
 
 func bind(scope *Scope, args []any) (any, error) {
 	if len(args) != 2 {
-		return nil, fmt.Errorf("bind expects 2 arguments: got %d", len(args))
+		return nil, newError(KindArity, NoPos, "bind expects 2 arguments: got %d", len(args))
 	}
-	var name string
-	switch k := args[0].(type) {
-	case *Op:
-		if len(k.Args) != 0 {
-			return nil, fmt.Errorf("not a valid name in arg 0 of bind: arg must be id or string")
-		}
-		name = k.Id
-	case *String:
-		// TODO(wes): Test contents for id conformity.
-		var err error
-		name, err = strconv.Unquote(k.QuotedContent)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unquote arg 0 of bind: %v", err)
-		}
-	default:
-		return nil, fmt.Errorf("not a valid name in arg 0 of bind: invalid type %T", k)
+	name, err := bindingName(args[0])
+	if err != nil {
+		return nil, newError(KindType, NoPos, "not a valid name in arg 0 of bind: %v", err)
+	}
+	if scope.Frozen() {
+		return nil, newError(KindEval, NoPos, "cannot bind %q in frozen scope", name)
 	}
 	v, err := Eval(scope, args[1])
 	if err != nil {
@@ -40,26 +152,51 @@ func bind(scope *Scope, args []any) (any, error) {
 	return nil, nil
 }
 
-func lambda(scope *Scope, args []any) (any, error) {
-	switch len(args) {
-	case 0:
-		return func(*Scope, []any) (any, error) { return nil, nil }, nil
-	case 1:
-		return func(*Scope, []any) (any, error) { return args[0], nil }, nil
+// bindingName returns the name given by an unevaluated id or string.
+func bindingName(v any) (string, error) {
+	switch k := v.(type) {
+	case *Op:
+		if len(k.Args) != 0 {
+			return "", fmt.Errorf("arg must be id or string")
+		}
+		return k.Id, nil
+	case string:
+		if !isIdent(k) {
+			return "", fmt.Errorf("string %q is not an id", k)
+		}
+		return k, nil
 	default:
-		return func(scope *Scope, as []any) (any, error) {
-			if len(as) != len(args)-1 {
-				return nil, fmt.Errorf("lambda expects %d argument, found %d", len(args)-1, len(as))
-			}
-			scope = scope.LocalScope()
-			for i, a := range args[:len(args)-1] {
-				v, ok := a.(*Op)
-				if !ok || len(v.Args) != 0 {
-					return nil, fmt.Errorf("not a valid variable in argument %d of lambda", i+1)
-				}
-				scope.Bind(v.Id, as[i])
-			}
-			return Eval(scope, args[len(args)-1])
-		}, nil
+		return "", fmt.Errorf("invalid type %s", TypeName(k))
+	}
+}
+
+// lambda returns an op which binds its parameters in a local scope of the
+// defining scope and evaluates the body. The last argument is the body and
+// the others are the parameter names. The returned op is strict so arguments
+// are evaluated in the caller's scope.
+func lambda(scope *Scope, args []any) (any, error) {
+	if len(args) == 0 {
+		return StrictFunc(func(*Scope, []any) (any, error) { return nil, nil }), nil
+	}
+	params := make([]string, len(args)-1)
+	for i, a := range args[:len(args)-1] {
+		v, ok := a.(*Op)
+		if !ok || len(v.Args) != 0 {
+			return nil, newError(KindType, NoPos, "not a valid variable in argument %d of lambda", i+1)
+		}
+		params[i] = v.Id
 	}
+	body := args[len(args)-1]
+	return StrictFunc(func(caller *Scope, as []any) (any, error) {
+		if len(as) != len(params) {
+			return nil, newError(KindArity, NoPos, "lambda expects %d argument, found %d", len(params), len(as))
+		}
+		// Track resources using the state of the caller, not that of the definition.
+		local := scope.LocalScope()
+		local.state = caller.evalState()
+		for i, v := range as {
+			local.Bind(params[i], v)
+		}
+		return Eval(local, body)
+	}), nil
 }
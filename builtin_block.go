@@ -0,0 +1,206 @@
+package jsondsl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// let binds the members of an object in a local scope and evaluates the body there.
+// Bindings may refer to each other and are evaluated in dependency order, since the
+// order of object members is not preserved. A binding may refer to itself only from
+// within a lambda body, allowing recursive definitions.
+func let(scope *Scope, args []any) (any, error) {
+	if err := checkArity("let", args, 2); err != nil {
+		return nil, err
+	}
+	bindings, ok := args[0].(map[any]any)
+	if !ok {
+		return nil, argTypeError("let", 0, "object", args[0])
+	}
+	vals := make(map[string]any, len(bindings))
+	for k, v := range bindings {
+		name, err := bindingName(k)
+		if err != nil {
+			return nil, newError(KindType, NoPos, "not a valid name in let binding: %v", err)
+		}
+		vals[name] = v
+	}
+	order, err := bindingOrder(vals)
+	if err != nil {
+		return nil, err
+	}
+	local := scope.LocalScope()
+	for _, name := range order {
+		v, err := Eval(local, vals[name])
+		if err != nil {
+			return nil, wrapError(err, fmt.Sprintf("in let binding %q", name))
+		}
+		local.Bind(name, v)
+	}
+	return Eval(local, args[1])
+}
+
+// bindingOrder returns the names of vals sorted so that each name comes after
+// the names its value refers to. Ties are broken by name for a deterministic order.
+//
+// Names referred to only from within lambda bodies are not needed until the lambda
+// is called, so they do not order the lambda itself. They order the bindings which
+// refer to the lambda instead, since those may call it while being evaluated.
+// This allows mutually recursive lambdas.
+func bindingOrder(vals map[string]any) ([]string, error) {
+	names := make([]string, 0, len(vals))
+	for name := range vals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	direct := make(map[string]map[string]bool, len(vals))
+	called := make(map[string]map[string]bool, len(vals))
+	for _, name := range names {
+		direct[name], called[name] = make(map[string]bool), make(map[string]bool)
+		freeNames(vals[name], nil, direct[name], called[name])
+	}
+	// deps returns the names referred to by name directly, together with
+	// those referred to from the bodies of the lambdas it may call.
+	deps := func(name string) []string {
+		refs := make(map[string]bool)
+		var add func(names map[string]bool)
+		add = func(names map[string]bool) {
+			for ref := range names {
+				if _, ok := vals[ref]; ok && !refs[ref] {
+					refs[ref] = true
+					add(called[ref])
+				}
+			}
+		}
+		add(direct[name])
+		delete(refs, name)
+		deps := make([]string, 0, len(refs))
+		for ref := range refs {
+			deps = append(deps, ref)
+		}
+		sort.Strings(deps)
+		return deps
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(vals))
+	order := make([]string, 0, len(vals))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return newError(KindEval, NoPos, "cycle in let bindings: %v", append(path, name))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range deps(name) {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// freeNames adds the ids of the ops found in the unevaluated value v to names,
+// or to lambdas if found within a lambda body. Ids bound within v by the
+// parameters of a lambda or the members of a let are skipped, as are ids in bound.
+func freeNames(v any, bound, names, lambdas map[string]bool) {
+	switch v := v.(type) {
+	case *Op:
+		if !bound[v.Id] {
+			names[v.Id] = true
+		}
+		if len(v.Args) == 1 {
+			switch args := v.Args[0]; {
+			case v.Id == "lambda" && len(args) > 0:
+				inner := withNames(bound, args[:len(args)-1])
+				freeNames(args[len(args)-1], inner, lambdas, lambdas)
+				return
+			case v.Id == "let" && len(args) == 2:
+				if m, ok := args[0].(map[any]any); ok {
+					keys := make([]any, 0, len(m))
+					for k := range m {
+						keys = append(keys, k)
+					}
+					inner := withNames(bound, keys)
+					for _, e := range m {
+						freeNames(e, inner, names, lambdas)
+					}
+					freeNames(args[1], inner, names, lambdas)
+					return
+				}
+			}
+		}
+		for _, args := range v.Args {
+			for _, a := range args {
+				freeNames(a, bound, names, lambdas)
+			}
+		}
+	case *SelectOp:
+		freeNames(v.X, bound, names, lambdas)
+		for _, args := range v.Sel.Args {
+			for _, a := range args {
+				freeNames(a, bound, names, lambdas)
+			}
+		}
+	case *IndexOp:
+		freeNames(v.X, bound, names, lambdas)
+		freeNames(v.Index, bound, names, lambdas)
+	case *SliceOp:
+		freeNames(v.X, bound, names, lambdas)
+		freeNames(v.Low, bound, names, lambdas)
+		freeNames(v.High, bound, names, lambdas)
+	case []any:
+		for _, e := range v {
+			freeNames(e, bound, names, lambdas)
+		}
+	case map[any]any:
+		for k, e := range v {
+			freeNames(k, bound, names, lambdas)
+			freeNames(e, bound, names, lambdas)
+		}
+	}
+}
+
+// withNames returns a copy of bound together with the valid binding names in vs.
+func withNames(bound map[string]bool, vs []any) map[string]bool {
+	inner := make(map[string]bool, len(bound)+len(vs))
+	for name := range bound {
+		inner[name] = true
+	}
+	for _, v := range vs {
+		if name, err := bindingName(v); err == nil {
+			inner[name] = true
+		}
+	}
+	return inner
+}
+
+// do evaluates each argument in order in a local scope and returns the value of the last.
+// Bindings made by the arguments do not outlive the do expression.
+func do(scope *Scope, args []any) (any, error) {
+	local := scope.LocalScope()
+	var res any
+	for _, a := range args {
+		v, err := Eval(local, a)
+		if err != nil {
+			return nil, err
+		}
+		res = v
+	}
+	return res, nil
+}
@@ -0,0 +1,91 @@
+package jsondsl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvalBlock(t *testing.T) {
+	src := `bind(x, 10)
+	bind("y", 1)
+	[
+		let({z: add(w, 2), w: add(x, 1), "v": 3}, [w, z, v]),
+		let({fact: lambda(n, if(le(n, 1), 1, mul(n, fact(sub(n, 1)))))}, fact(5)),
+		do(bind(x, 2), bind(t, add(x, y)), t),
+		do(),
+		x,
+	]`
+
+	scope := BuiltinScope()
+	got, err := EvalSource(scope, src)
+
+	wantErr := false
+	want := []any{
+		[]any{float64(11), float64(13), float64(3)},
+		float64(120),
+		float64(3),
+		nil,
+		float64(10),
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalBlock(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalBlock(): got diff:\n%s", diff)
+	}
+	for _, name := range []string{"w", "z", "v", "fact", "t"} {
+		if _, err := scope.Lookup(name); err == nil {
+			t.Errorf("TestEvalBlock(): got %q bound in scope, want unbound", name)
+		}
+	}
+}
+
+func TestEvalLetCycle(t *testing.T) {
+	src := `let({a: b, b: add(a, 1)}, a)`
+
+	_, err := EvalSource(BuiltinScope(), src)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestEvalLetCycle(): got err = %v, want *Error", err)
+	}
+	if got.Kind != KindEval || got.Msg != "cycle in let bindings: [a b a]" {
+		t.Errorf("TestEvalLetCycle(): got err = %v (kind %v), want cycle error", err, got.Kind)
+	}
+}
+
+func TestEvalLetLambdas(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want any
+	}{
+		{`let({a: lambda(b, b), b: lambda(a, a)}, [a(1), b(2)])`, []any{float64(1), float64(2)}},
+		{`let({even: lambda(n, if(eq(n, 0), true, odd(sub(n, 1)))), odd: lambda(n, if(eq(n, 0), false, even(sub(n, 1))))}, [even(10), odd(7)])`, []any{true, true}},
+		{`let({f: lambda(x, add(x, z)), y: f(2), z: 1}, y)`, float64(3)},
+		{`let({x: let({y: 1}, y), y: add(x, 1)}, y)`, float64(2)},
+	} {
+		got, err := EvalSource(BuiltinScope(), tc.src)
+		if err != nil {
+			t.Fatalf("TestEvalLetLambdas(%q): got err = %v, want err = false", tc.src, err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("TestEvalLetLambdas(%q): got diff:\n%s", tc.src, diff)
+		}
+
+		p, err := Compile(BuiltinScope(), decodeString(t, tc.src))
+		if err != nil {
+			t.Fatalf("TestEvalLetLambdas(%q): got Compile err = %v, want err = false", tc.src, err)
+		}
+		got, err = p.Run(nil)
+		if err != nil {
+			t.Fatalf("TestEvalLetLambdas(%q): got Run err = %v, want err = false", tc.src, err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("TestEvalLetLambdas(%q): got Run diff:\n%s", tc.src, diff)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package jsondsl
+
+import (
+	"math"
+	"sort"
+)
+
+// arrayArg returns argument i of name as an array.
+func arrayArg(name string, args []any, i int) ([]any, error) {
+	a, ok := args[i].([]any)
+	if !ok {
+		return nil, argTypeError(name, i, "array", args[i])
+	}
+	return a, nil
+}
+
+// objectArg returns argument i of name as an object.
+func objectArg(name string, args []any, i int) (map[any]any, error) {
+	m, ok := args[i].(map[any]any)
+	if !ok {
+		return nil, argTypeError(name, i, "object", args[i])
+	}
+	return m, nil
+}
+
+// funcArg returns argument i of name as an op.
+func funcArg(name string, args []any, i int) (StrictFunc, error) {
+	fn, ok := asStrictFunc(args[i])
+	if !ok {
+		return nil, argTypeError(name, i, "op", args[i])
+	}
+	return fn, nil
+}
+
+// keyArg returns argument i of name as a hashable object key.
+func keyArg(name string, args []any, i int) (any, error) {
+	switch k := args[i].(type) {
+	case nil, bool, float64, string:
+		return k, nil
+	default:
+		return nil, argTypeError(name, i, "null, bool, number, or string", k)
+	}
+}
+
+// call calls fn with the evaluated values args.
+func call(scope *Scope, fn StrictFunc, args ...any) (any, error) {
+	return fn(scope, args)
+}
+
+func mapOp(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("map", args, 2, 2); err != nil {
+		return nil, err
+	}
+	xs, err := arrayArg("map", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := funcArg("map", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]any, len(xs))
+	for i, x := range xs {
+		if res[i], err = call(scope, fn, x); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func filter(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("filter", args, 2, 2); err != nil {
+		return nil, err
+	}
+	xs, err := arrayArg("filter", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := funcArg("filter", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]any, 0, len(xs))
+	for _, x := range xs {
+		ok, err := call(scope, fn, x)
+		if err != nil {
+			return nil, err
+		}
+		if AsBool(ok) {
+			res = append(res, x)
+		}
+	}
+	return res, nil
+}
+
+// reduce folds the array using fn(acc, x). The initial value defaults to the first element.
+func reduce(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("reduce", args, 2, 3); err != nil {
+		return nil, err
+	}
+	xs, err := arrayArg("reduce", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := funcArg("reduce", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var acc any
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(xs) == 0 {
+			return nil, newError(KindEval, NoPos, "reduce of empty array with no initial value")
+		}
+		acc, xs = xs[0], xs[1:]
+	}
+	for _, x := range xs {
+		if acc, err = call(scope, fn, acc, x); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// rangeOp returns the numbers from start up to but not including end.
+// It accepts range(end), range(start, end), and range(start, end, step).
+func rangeOp(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("range", args, 1, 3); err != nil {
+		return nil, err
+	}
+	xs := make([]float64, len(args))
+	for i := range args {
+		var err error
+		if xs[i], err = numberArg("range", args, i); err != nil {
+			return nil, err
+		}
+	}
+	start, end, step := 0.0, xs[0], 1.0
+	if len(xs) > 1 {
+		start, end = xs[0], xs[1]
+	}
+	if len(xs) > 2 {
+		step = xs[2]
+	}
+	if step == 0 {
+		return nil, newError(KindEval, NoPos, "range step must not be zero")
+	}
+	// Count the elements up front, since adding a small step to a large
+	// start may not change it. Indices are limited to int32 as well.
+	n := math.Ceil((end - start) / step)
+	if math.IsNaN(n) {
+		return nil, newError(KindEval, NoPos, "range of %v elements", n)
+	}
+	res := []any{}
+	if n <= 0 {
+		return res, nil
+	}
+	if err := scope.evalState().reserve(n); err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt32 {
+		return nil, newError(KindEval, NoPos, "range of %v elements is too large", n)
+	}
+	ctx := scope.Context()
+	for i := 0; i < int(n); i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, limitError(err)
+			}
+		}
+		res = append(res, start+float64(i)*step)
+	}
+	return res, nil
+}
+
+// flatten concatenates the array elements of an array by one level.
+// Other elements are kept as is.
+func flatten(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("flatten", args, 1, 1); err != nil {
+		return nil, err
+	}
+	xs, err := arrayArg("flatten", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]any, 0, len(xs))
+	for _, x := range xs {
+		if a, ok := x.([]any); ok {
+			res = append(res, a...)
+		} else {
+			res = append(res, x)
+		}
+	}
+	return res, nil
+}
+
+// sortOp returns a sorted copy of an array of numbers or strings.
+// The optional second argument is an op reporting whether its first argument is less than its second.
+// The sort is stable.
+func sortOp(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("sort", args, 1, 2); err != nil {
+		return nil, err
+	}
+	xs, err := arrayArg("sort", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	less := func(a, b any) (bool, error) {
+		c, err := compare("sort", a, b)
+		return c < 0, err
+	}
+	if len(args) == 2 {
+		fn, err := funcArg("sort", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		less = func(a, b any) (bool, error) {
+			v, err := call(scope, fn, a, b)
+			return AsBool(v), err
+		}
+	}
+	res := append([]any{}, xs...)
+	sort.SliceStable(res, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var ok bool
+		ok, err = less(res[i], res[j])
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// zip returns an array of arrays grouping the ith elements of each argument.
+// The result is as long as the shortest argument.
+func zip(scope *Scope, args []any) (any, error) {
+	arrays := make([][]any, len(args))
+	n := -1
+	for i := range args {
+		var err error
+		if arrays[i], err = arrayArg("zip", args, i); err != nil {
+			return nil, err
+		}
+		if n < 0 || len(arrays[i]) < n {
+			n = len(arrays[i])
+		}
+	}
+	res := make([]any, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		t := make([]any, len(arrays))
+		for j, a := range arrays {
+			t[j] = a[i]
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// sortedKeys returns the keys of m in the order used by Marshal.
+func sortedKeys(m map[any]any) []any {
+	keys := make([]any, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// objectOp returns an op taking a single object and returning a value derived from its sorted keys.
+func objectOp(name string, fn func(m map[any]any, keys []any) any) StrictFunc {
+	return func(scope *Scope, args []any) (any, error) {
+		if err := checkArgsN(name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		m, err := objectArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(m, sortedKeys(m)), nil
+	}
+}
+
+var (
+	keys = objectOp("keys", func(m map[any]any, keys []any) any {
+		return keys
+	})
+	values = objectOp("values", func(m map[any]any, keys []any) any {
+		res := make([]any, len(keys))
+		for i, k := range keys {
+			res[i] = m[k]
+		}
+		return res
+	})
+	entries = objectOp("entries", func(m map[any]any, keys []any) any {
+		res := make([]any, len(keys))
+		for i, k := range keys {
+			res[i] = []any{k, m[k]}
+		}
+		return res
+	})
+)
+
+// merge returns a new object with the members of each argument.
+// Later arguments take precedence.
+func merge(scope *Scope, args []any) (any, error) {
+	res := make(map[any]any)
+	for i := range args {
+		m, err := objectArg("merge", args, i)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+// get returns the value of a key in an object or the default value, which is null if not given.
+func get(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("get", args, 2, 3); err != nil {
+		return nil, err
+	}
+	m, err := objectArg("get", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := keyArg("get", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := m[k]; ok {
+		return v, nil
+	}
+	if len(args) == 3 {
+		return args[2], nil
+	}
+	return nil, nil
+}
+
+func has(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("has", args, 2, 2); err != nil {
+		return nil, err
+	}
+	m, err := objectArg("has", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := keyArg("has", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := m[k]
+	return ok, nil
+}
+
+// set returns a copy of the object with the key set to the value.
+func set(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("set", args, 3, 3); err != nil {
+		return nil, err
+	}
+	m, err := objectArg("set", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := keyArg("set", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	res := copyObject(m, 1)
+	res[k] = args[2]
+	return res, nil
+}
+
+// del returns a copy of the object without the key.
+func del(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("del", args, 2, 2); err != nil {
+		return nil, err
+	}
+	m, err := objectArg("del", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := keyArg("del", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	res := copyObject(m, 0)
+	delete(res, k)
+	return res, nil
+}
+
+// copyObject returns a shallow copy of m with room for extra members.
+func copyObject(m map[any]any, extra int) map[any]any {
+	res := make(map[any]any, len(m)+extra)
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
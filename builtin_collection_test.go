@@ -0,0 +1,76 @@
+package jsondsl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvalCollection(t *testing.T) {
+	src := `bind(xs, [3, 1, 2])
+	bind(o, {"a": 1, "b": 2})
+	[
+		map(xs, lambda(x, mul(x, 2))),
+		filter(xs, lambda(x, gt(x, 1))),
+		reduce(xs, add),
+		reduce([], add, 0),
+		range(3),
+		range(5, 0, -2),
+		len(xs),
+		len(o),
+		concat(xs, [4], []),
+		flatten([[1, [2]], 3]),
+		sort(xs),
+		sort(xs, gt),
+		zip(xs, ["a", "b"]),
+		keys(o),
+		values(o),
+		entries(o),
+		merge(o, {"b": 3, "c": 4}),
+		get(o, "a"),
+		get(o, "z", 0),
+		has(o, "b"),
+		set(o, "c", 3),
+		del(o, "a"),
+		o,
+		xs,
+	]`
+
+	got, err := EvalSource(BuiltinScope(), src)
+
+	wantErr := false
+	want := []any{
+		[]any{float64(6), float64(2), float64(4)},
+		[]any{float64(3), float64(2)},
+		float64(6),
+		float64(0),
+		[]any{float64(0), float64(1), float64(2)},
+		[]any{float64(5), float64(3), float64(1)},
+		float64(3),
+		float64(2),
+		[]any{float64(3), float64(1), float64(2), float64(4)},
+		[]any{float64(1), []any{float64(2)}, float64(3)},
+		[]any{float64(1), float64(2), float64(3)},
+		[]any{float64(3), float64(2), float64(1)},
+		[]any{[]any{float64(3), "a"}, []any{float64(1), "b"}},
+		[]any{"a", "b"},
+		[]any{float64(1), float64(2)},
+		[]any{[]any{"a", float64(1)}, []any{"b", float64(2)}},
+		map[any]any{"a": float64(1), "b": float64(3), "c": float64(4)},
+		float64(1),
+		float64(0),
+		true,
+		map[any]any{"a": float64(1), "b": float64(2), "c": float64(3)},
+		map[any]any{"b": float64(2)},
+		map[any]any{"a": float64(1), "b": float64(2)},
+		[]any{float64(3), float64(1), float64(2)},
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalCollection(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalCollection(): got diff:\n%s", diff)
+	}
+}
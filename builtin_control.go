@@ -0,0 +1,103 @@
+package jsondsl
+
+// ifOp evaluates the condition and then only the branch taken.
+// The else branch is optional and defaults to null.
+func ifOp(scope *Scope, args []any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, newError(KindArity, NoPos, "if expects 2 or 3 arguments: got %d", len(args))
+	}
+	c, err := Eval(scope, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if AsBool(c) {
+		return Eval(scope, args[1])
+	}
+	if len(args) == 3 {
+		return Eval(scope, args[2])
+	}
+	return nil, nil
+}
+
+// cond evaluates conditions in order and returns the value paired with the first true one.
+// A trailing unpaired argument is the default value, otherwise the default is null.
+func cond(scope *Scope, args []any) (any, error) {
+	for ; len(args) >= 2; args = args[2:] {
+		c, err := Eval(scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if AsBool(c) {
+			return Eval(scope, args[1])
+		}
+	}
+	if len(args) == 1 {
+		return Eval(scope, args[0])
+	}
+	return nil, nil
+}
+
+// and returns the first false argument or the last argument.
+// Arguments after the first false argument are not evaluated.
+func and(scope *Scope, args []any) (any, error) {
+	var v any = true
+	for _, a := range args {
+		var err error
+		if v, err = Eval(scope, a); err != nil {
+			return nil, err
+		}
+		if !AsBool(v) {
+			return v, nil
+		}
+	}
+	return v, nil
+}
+
+// or returns the first true argument or the last argument.
+// Arguments after the first true argument are not evaluated.
+func or(scope *Scope, args []any) (any, error) {
+	var v any = false
+	for _, a := range args {
+		var err error
+		if v, err = Eval(scope, a); err != nil {
+			return nil, err
+		}
+		if AsBool(v) {
+			return v, nil
+		}
+	}
+	return v, nil
+}
+
+func not(scope *Scope, args []any) (any, error) {
+	if err := checkArity("not", args, 1); err != nil {
+		return nil, err
+	}
+	return !AsBool(args[0]), nil
+}
+
+// switchOp compares the value of the first argument against each case in order
+// and returns the value paired with the first equal case.
+// A trailing unpaired argument is the default value, otherwise the default is null.
+func switchOp(scope *Scope, args []any) (any, error) {
+	if err := checkMinArity("switch", args, 1); err != nil {
+		return nil, err
+	}
+	x, err := Eval(scope, args[0])
+	if err != nil {
+		return nil, err
+	}
+	for args = args[1:]; len(args) >= 2; args = args[2:] {
+		c, err := Eval(scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if Equal(x, c) {
+			return Eval(scope, args[1])
+		}
+	}
+	if len(args) == 1 {
+		return Eval(scope, args[0])
+	}
+	return nil, nil
+}
@@ -0,0 +1,49 @@
+package jsondsl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvalControl(t *testing.T) {
+	src := `bind(x, 7)
+	[
+		if(gt(x, 5), "big", undefined),
+		if(lt(x, 5), undefined),
+		cond(lt(x, 0), undefined, lt(x, 10), "small", undefined),
+		cond(false, undefined, "default"),
+		and(1, "", undefined),
+		and(1, 2),
+		or(null, 0, "x", undefined),
+		or(),
+		not([]),
+		switch(x, 1, undefined, 7, "seven", undefined),
+		switch("z", "a", 1, "none"),
+	]`
+
+	got, err := EvalSource(BuiltinScope(), src)
+
+	wantErr := false
+	want := []any{
+		"big",
+		nil,
+		"small",
+		"default",
+		"",
+		float64(2),
+		"x",
+		false,
+		true,
+		"seven",
+		"none",
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalControl(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalControl(): got diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,151 @@
+package jsondsl
+
+import "math"
+
+// numberOp returns an op taking a fixed number of number arguments.
+func numberOp(name string, n int, fn func(xs []float64) (any, error)) StrictFunc {
+	return func(scope *Scope, args []any) (any, error) {
+		if err := checkArity(name, args, n); err != nil {
+			return nil, err
+		}
+		return numberArgs(name, args, fn)
+	}
+}
+
+// variadicNumberOp returns an op taking at least min number arguments.
+func variadicNumberOp(name string, min int, fn func(xs []float64) (any, error)) StrictFunc {
+	return func(scope *Scope, args []any) (any, error) {
+		if err := checkMinArity(name, args, min); err != nil {
+			return nil, err
+		}
+		return numberArgs(name, args, fn)
+	}
+}
+
+// numberArgs calls fn with args after checking they are all numbers.
+func numberArgs(name string, args []any, fn func(xs []float64) (any, error)) (any, error) {
+	xs := make([]float64, len(args))
+	for i := range args {
+		var err error
+		if xs[i], err = numberArg(name, args, i); err != nil {
+			return nil, err
+		}
+	}
+	return fn(xs)
+}
+
+var (
+	add = variadicNumberOp("add", 0, func(xs []float64) (any, error) {
+		var sum float64
+		for _, x := range xs {
+			sum += x
+		}
+		return sum, nil
+	})
+	mul = variadicNumberOp("mul", 0, func(xs []float64) (any, error) {
+		prod := float64(1)
+		for _, x := range xs {
+			prod *= x
+		}
+		return prod, nil
+	})
+	sub = numberOp("sub", 2, func(xs []float64) (any, error) { return xs[0] - xs[1], nil })
+	div = numberOp("div", 2, func(xs []float64) (any, error) {
+		if xs[1] == 0 {
+			return nil, newError(KindEval, NoPos, "division by zero")
+		}
+		return xs[0] / xs[1], nil
+	})
+	mod = numberOp("mod", 2, func(xs []float64) (any, error) {
+		if xs[1] == 0 {
+			return nil, newError(KindEval, NoPos, "division by zero")
+		}
+		return math.Mod(xs[0], xs[1]), nil
+	})
+	neg   = numberOp("neg", 1, func(xs []float64) (any, error) { return -xs[0], nil })
+	abs   = numberOp("abs", 1, func(xs []float64) (any, error) { return math.Abs(xs[0]), nil })
+	floor = numberOp("floor", 1, func(xs []float64) (any, error) { return math.Floor(xs[0]), nil })
+	ceil  = numberOp("ceil", 1, func(xs []float64) (any, error) { return math.Ceil(xs[0]), nil })
+	round = numberOp("round", 1, func(xs []float64) (any, error) { return math.Round(xs[0]), nil })
+	minOp = variadicNumberOp("min", 1, func(xs []float64) (any, error) {
+		m := xs[0]
+		for _, x := range xs[1:] {
+			m = math.Min(m, x)
+		}
+		return m, nil
+	})
+	maxOp = variadicNumberOp("max", 1, func(xs []float64) (any, error) {
+		m := xs[0]
+		for _, x := range xs[1:] {
+			m = math.Max(m, x)
+		}
+		return m, nil
+	})
+)
+
+func eq(scope *Scope, args []any) (any, error) {
+	if err := checkArity("eq", args, 2); err != nil {
+		return nil, err
+	}
+	return Equal(args[0], args[1]), nil
+}
+
+func ne(scope *Scope, args []any) (any, error) {
+	if err := checkArity("ne", args, 2); err != nil {
+		return nil, err
+	}
+	return !Equal(args[0], args[1]), nil
+}
+
+// compareOp returns an op comparing two numbers or two strings.
+func compareOp(name string, fn func(c int) bool) StrictFunc {
+	return func(scope *Scope, args []any) (any, error) {
+		if err := checkArity(name, args, 2); err != nil {
+			return nil, err
+		}
+		c, err := compare(name, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return fn(c), nil
+	}
+}
+
+var (
+	lt = compareOp("lt", func(c int) bool { return c < 0 })
+	le = compareOp("le", func(c int) bool { return c <= 0 })
+	gt = compareOp("gt", func(c int) bool { return c > 0 })
+	ge = compareOp("ge", func(c int) bool { return c >= 0 })
+)
+
+// compare returns -1, 0, or 1 comparing two numbers or two strings.
+func compare(name string, a, b any) (int, error) {
+	switch a := a.(type) {
+	case float64:
+		fb, ok := b.(float64)
+		if !ok {
+			return 0, argTypeError(name, 1, "number", b)
+		}
+		switch {
+		case a < fb:
+			return -1, nil
+		case a > fb:
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		sb, ok := b.(string)
+		if !ok {
+			return 0, argTypeError(name, 1, "string", b)
+		}
+		switch {
+		case a < sb:
+			return -1, nil
+		case a > sb:
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, argTypeError(name, 0, "number or string", a)
+	}
+}
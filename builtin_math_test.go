@@ -0,0 +1,71 @@
+package jsondsl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvalMath(t *testing.T) {
+	src := `bind(x, 7)
+	[
+		add(), add(1, 2, x), sub(x, 10), mul(2, 3, 4), div(x, 2), mod(x, 4), neg(x),
+		abs(-2), floor(1.5), ceil(1.5), round(2.5), min(3, 1, 2), max(3, 1, 2),
+		eq([1, "a"], [1, "a"]), eq({"a": null}, {"a": false}), ne(1, "1"),
+		lt(1, 2), le(2, 2), gt("b", "a"), ge("a", "b"),
+	]`
+
+	got, err := EvalSource(BuiltinScope(), src)
+
+	wantErr := false
+	want := []any{
+		float64(0), float64(10), float64(-3), float64(24), 3.5, float64(3), float64(-7),
+		float64(2), float64(1), float64(2), float64(3), float64(1), float64(3),
+		true, false, true,
+		true, true, true, false,
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalMath(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalMath(): got diff:\n%s", diff)
+	}
+}
+
+func TestEvalMathTypeError(t *testing.T) {
+	src := `add(1, "2")`
+
+	_, err := EvalSource(BuiltinScope(), src)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestEvalMathTypeError(): got err = %v, want *Error", err)
+	}
+	if got.Kind != KindType || got.Msg != "add expects number in argument 1: found string" {
+		t.Errorf("TestEvalMathTypeError(): got err = %v (kind %v), want type error", err, got.Kind)
+	}
+}
+
+func TestEvalCompareTypeError(t *testing.T) {
+	for _, tc := range []struct {
+		src string
+		msg string
+	}{
+		{`lt(1, "a")`, "lt expects number in argument 1: found string"},
+		{`ge("a", 1)`, "ge expects string in argument 1: found number"},
+		{`gt(null, 1)`, "gt expects number or string in argument 0: found null"},
+	} {
+		_, err := EvalSource(BuiltinScope(), tc.src)
+		var got *Error
+		if !errors.As(err, &got) {
+			t.Errorf("TestEvalCompareTypeError(%q): got err = %v, want *Error", tc.src, err)
+			continue
+		}
+		if got.Kind != KindType || got.Msg != tc.msg {
+			t.Errorf("TestEvalCompareTypeError(%q): got err = %q (kind %v), want %q", tc.src, got.Msg, got.Kind, tc.msg)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package jsondsl
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stringArg returns argument i of name as a string.
+func stringArg(name string, args []any, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", argTypeError(name, i, "string", args[i])
+	}
+	return s, nil
+}
+
+// stringOp returns an op taking between min and max string arguments.
+func stringOp(name string, min, max int, fn func(scope *Scope, ss []string) (any, error)) StrictFunc {
+	return func(scope *Scope, args []any) (any, error) {
+		if err := checkArgsN(name, args, min, max); err != nil {
+			return nil, err
+		}
+		ss := make([]string, len(args))
+		for i := range args {
+			var err error
+			if ss[i], err = stringArg(name, args, i); err != nil {
+				return nil, err
+			}
+		}
+		return fn(scope, ss)
+	}
+}
+
+var (
+	split = stringOp("split", 2, 2, func(scope *Scope, ss []string) (any, error) {
+		parts := strings.Split(ss[0], ss[1])
+		res := make([]any, len(parts))
+		for i, p := range parts {
+			res[i] = p
+		}
+		return res, nil
+	})
+	upper = stringOp("upper", 1, 1, func(scope *Scope, ss []string) (any, error) {
+		return strings.ToUpper(ss[0]), nil
+	})
+	lower = stringOp("lower", 1, 1, func(scope *Scope, ss []string) (any, error) {
+		return strings.ToLower(ss[0]), nil
+	})
+	// trim removes leading and trailing white space, or the characters in the optional cutset.
+	trim = stringOp("trim", 1, 2, func(scope *Scope, ss []string) (any, error) {
+		if len(ss) == 2 {
+			return strings.Trim(ss[0], ss[1]), nil
+		}
+		return strings.TrimSpace(ss[0]), nil
+	})
+	replace = stringOp("replace", 3, 3, func(scope *Scope, ss []string) (any, error) {
+		s, old, repl := ss[0], ss[1], ss[2]
+		m := strings.Count(s, old)
+		if err := scope.evalState().reserve(float64(len(s)) + float64(m)*float64(len(repl)-len(old))); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(s, old, repl), nil
+	})
+	contains = stringOp("contains", 2, 2, func(scope *Scope, ss []string) (any, error) {
+		return strings.Contains(ss[0], ss[1]), nil
+	})
+	startsWith = stringOp("startsWith", 2, 2, func(scope *Scope, ss []string) (any, error) {
+		return strings.HasPrefix(ss[0], ss[1]), nil
+	})
+	match = stringOp("match", 2, 2, func(scope *Scope, ss []string) (any, error) {
+		re, err := compileRegexp("match", ss[1])
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(ss[0]), nil
+	})
+	findAll = stringOp("findAll", 2, 2, func(scope *Scope, ss []string) (any, error) {
+		re, err := compileRegexp("findAll", ss[1])
+		if err != nil {
+			return nil, err
+		}
+		res := []any{}
+		for _, m := range re.FindAllString(ss[0], -1) {
+			res = append(res, m)
+		}
+		return res, nil
+	})
+	// replaceAll replaces matches of the pattern, expanding $1 style references in the replacement.
+	replaceAll = stringOp("replaceAll", 3, 3, func(scope *Scope, ss []string) (any, error) {
+		re, err := compileRegexp("replaceAll", ss[1])
+		if err != nil {
+			return nil, err
+		}
+		// Bound the size of the result by expanding each reference to the whole match,
+		// which contains the submatches.
+		s, repl := ss[0], ss[2]
+		refs := float64(strings.Count(repl, "$"))
+		n := float64(len(s))
+		for _, m := range re.FindAllStringIndex(s, -1) {
+			n += float64(len(repl)) + (refs-1)*float64(m[1]-m[0])
+		}
+		if err := scope.evalState().reserve(n); err != nil {
+			return nil, err
+		}
+		return re.ReplaceAllString(s, repl), nil
+	})
+)
+
+// regexpCacheSize bounds the number of patterns cached by compileRegexp.
+const regexpCacheSize = 256
+
+// regexpCache caches the most recently used compiled patterns.
+var regexpCache = struct {
+	sync.Mutex
+	lru     *list.List               // Values are *regexp.Regexp, most recently used first.
+	entries map[string]*list.Element // Entries of lru by pattern.
+}{lru: list.New(), entries: make(map[string]*list.Element)}
+
+// compileRegexp returns the compiled RE2 pattern expr, caching the result.
+func compileRegexp(name, expr string) (*regexp.Regexp, error) {
+	c := &regexpCache
+	c.Lock()
+	if e, ok := c.entries[expr]; ok {
+		c.lru.MoveToFront(e)
+		c.Unlock()
+		return e.Value.(*regexp.Regexp), nil
+	}
+	c.Unlock()
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, newError(KindEval, NoPos, "%s: invalid pattern: %v", name, err)
+	}
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.entries[expr]; !ok {
+		c.entries[expr] = c.lru.PushFront(re)
+		if c.lru.Len() > regexpCacheSize {
+			last := c.lru.Back()
+			c.lru.Remove(last)
+			delete(c.entries, last.Value.(*regexp.Regexp).String())
+		}
+	}
+	return re, nil
+}
+
+// join concatenates an array of strings placing the separator between elements.
+func join(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("join", args, 2, 2); err != nil {
+		return nil, err
+	}
+	xs, err := arrayArg("join", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringArg("join", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	ss := make([]string, len(xs))
+	for i, x := range xs {
+		s, ok := x.(string)
+		if !ok {
+			return nil, newError(KindType, NoPos, "join expects string at array index %d: found %s", i, TypeName(x))
+		}
+		ss[i] = s
+	}
+	return strings.Join(ss, sep), nil
+}
+
+// substr returns the characters of a string from start up to but not including end.
+// Indices count characters rather than bytes and end defaults to the length of the string.
+func substr(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("substr", args, 2, 3); err != nil {
+		return nil, err
+	}
+	s, err := stringArg("substr", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	rs := []rune(s)
+	bounds := []int{0, len(rs)}
+	for i := 1; i < len(args); i++ {
+		x, err := numberArg("substr", args, i)
+		if err != nil {
+			return nil, err
+		}
+		if x != math.Trunc(x) || x < 0 || x > float64(len(rs)) {
+			return nil, newError(KindEval, NoPos, "substr index %v out of range [0, %d]", x, len(rs))
+		}
+		bounds[i-1] = int(x)
+	}
+	if bounds[0] > bounds[1] {
+		return nil, newError(KindEval, NoPos, "substr start %d after end %d", bounds[0], bounds[1])
+	}
+	return string(rs[bounds[0]:bounds[1]]), nil
+}
+
+// format returns the arguments formatted according to a printf-style format string.
+// Numbers are accepted by integer verbs such as %d provided they are integers, and
+// %v formats values as they would be encoded by Marshal.
+func format(scope *Scope, args []any) (any, error) {
+	if err := checkMinArity("format", args, 1); err != nil {
+		return nil, err
+	}
+	f, err := stringArg("format", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.evalState().reserve(float64(formatWidth(f))); err != nil {
+		return nil, err
+	}
+	// Arguments may be formatted any number of times, as by %[1]v, so count
+	// the size of each before it is written.
+	b := &formatBudget{state: scope.evalState(), n: float64(len(f))}
+	fargs := make([]any, len(args)-1)
+	for i, v := range args[1:] {
+		fargs[i] = formatArg{v, b}
+	}
+	res := fmt.Sprintf(f, fargs...)
+	if b.err != nil {
+		return nil, b.err
+	}
+	return res, nil
+}
+
+// formatBudget counts the size of the result of format against the allocation limit.
+type formatBudget struct {
+	state *evalState
+	n     float64 // Size of the result so far.
+	err   error   // Error of the first failed reserve.
+}
+
+// reserve reports whether n more bytes fit the allocation limit.
+func (b *formatBudget) reserve(n int) bool {
+	if b.err != nil {
+		return false
+	}
+	b.n += float64(n)
+	b.err = b.state.reserve(b.n)
+	return b.err == nil
+}
+
+// formatWidth returns the sum of the widths and precisions of the verbs in f,
+// which bounds the size of padding added by fmt.
+func formatWidth(f string) int {
+	n := 0
+	verb := false // Whether f[i] is within a verb.
+	for i := 0; i < len(f); i++ {
+		switch c := f[i]; {
+		case !verb:
+			verb = c == '%'
+		case '0' <= c && c <= '9':
+			x := 0
+			for ; i < len(f) && '0' <= f[i] && f[i] <= '9'; i++ {
+				x = min(x*10+int(f[i]-'0'), math.MaxInt32)
+			}
+			i--
+			n = min(n+x, math.MaxInt32)
+		case c == '%' || unicode.IsLetter(rune(c)):
+			verb = false
+		}
+	}
+	return n
+}
+
+// formatArg adapts DSL values to the verbs of package fmt.
+type formatArg struct {
+	v      any
+	budget *formatBudget
+}
+
+func (a formatArg) Format(f fmt.State, verb rune) {
+	if a.budget.err != nil {
+		return
+	}
+	var b strings.Builder
+	a.format(&b, f, verb)
+	if a.budget.reserve(b.Len()) {
+		io.WriteString(f, b.String())
+	}
+}
+
+// format writes the value of a to w as formatted by the verb with the flags of f.
+func (a formatArg) format(w io.Writer, f fmt.State, verb rune) {
+	switch v := a.v.(type) {
+	case float64:
+		switch verb {
+		case 'd', 'b', 'o', 'O', 'x', 'X', 'c', 'q', 'U':
+			if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+				fmt.Fprintf(w, fmt.FormatString(f, verb), int64(v))
+				return
+			}
+		case 'v':
+			if s, err := formatNumber(v); err == nil {
+				fmt.Fprintf(w, fmt.FormatString(f, 's'), s)
+				return
+			}
+		}
+	case nil, []any, map[any]any:
+		if verb == 'v' || verb == 's' {
+			if b, err := Marshal(v); err == nil {
+				fmt.Fprintf(w, fmt.FormatString(f, 's'), b)
+				return
+			}
+		}
+	}
+	fmt.Fprintf(w, fmt.FormatString(f, verb), a.v)
+}
+
+// concat concatenates strings or arrays.
+// The type of the first argument determines which is expected.
+func concat(scope *Scope, args []any) (any, error) {
+	if len(args) > 0 {
+		if _, ok := args[0].(string); ok {
+			ss := make([]string, len(args))
+			n := 0
+			for i := range args {
+				var err error
+				if ss[i], err = stringArg("concat", args, i); err != nil {
+					return nil, err
+				}
+				n += len(ss[i])
+			}
+			if err := scope.evalState().reserve(float64(n)); err != nil {
+				return nil, err
+			}
+			return strings.Join(ss, ""), nil
+		}
+	}
+	arrays := make([][]any, len(args))
+	n := 0
+	for i := range args {
+		var err error
+		if arrays[i], err = arrayArg("concat", args, i); err != nil {
+			return nil, err
+		}
+		n += len(arrays[i])
+	}
+	if err := scope.evalState().reserve(float64(n)); err != nil {
+		return nil, err
+	}
+	res := make([]any, 0, n)
+	for _, xs := range arrays {
+		res = append(res, xs...)
+	}
+	return res, nil
+}
+
+// length returns the number of characters in a string or the number of elements
+// in an array or object.
+func length(scope *Scope, args []any) (any, error) {
+	if err := checkArgsN("len", args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []any:
+		return float64(len(v)), nil
+	case map[any]any:
+		return float64(len(v)), nil
+	default:
+		return nil, argTypeError("len", 0, "string, array, or object", v)
+	}
+}
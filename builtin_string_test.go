@@ -0,0 +1,106 @@
+package jsondsl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvalString(t *testing.T) {
+	src := `bind(s, "  Hello, wörld  ")
+	[
+		concat("a", "b", "c"),
+		format("%s=%d %v %.1f %v", "x", 3, 2.5, 1, [1, "a"]),
+		split("a,b,c", ","),
+		join(["a", "b"], "-"),
+		upper("abc"),
+		lower("ABC"),
+		trim(s),
+		trim("xxaxx", "x"),
+		replace("aaa", "a", "b"),
+		contains(s, "wör"),
+		startsWith("abc", "ab"),
+		substr("wörld", 1, 3),
+		substr("wörld", 2),
+		len("wörld"),
+		match("abc123", "^[a-z]+[0-9]+$"),
+		findAll("a1b22c333", "[0-9]+"),
+		replaceAll("2024-01-02", "(\\d+)-(\\d+)-(\\d+)", "$3/$2/$1"),
+	]`
+
+	got, err := EvalSource(BuiltinScope(), src)
+
+	wantErr := false
+	want := []any{
+		"abc",
+		`x=3 2.5 1.0 [1,"a"]`,
+		[]any{"a", "b", "c"},
+		"a-b",
+		"ABC",
+		"abc",
+		"Hello, wörld",
+		"a",
+		"bbb",
+		true,
+		true,
+		"ör",
+		"rld",
+		float64(5),
+		true,
+		[]any{"1", "22", "333"},
+		"02/01/2024",
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalString(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalString(): got diff:\n%s", diff)
+	}
+}
+
+func TestFormatWidth(t *testing.T) {
+	for _, tc := range []struct {
+		f    string
+		want int
+	}{
+		{"no verbs 123", 0},
+		{"%d %s", 0},
+		{"%5d|%-10s|%08.3f", 5 + 10 + 8 + 3},
+		{"100%% %[2]*d", 2},
+		{"%999999999999999999999d", math.MaxInt32},
+	} {
+		if got := formatWidth(tc.f); got != tc.want {
+			t.Errorf("formatWidth(%q): got %d, want %d", tc.f, got, tc.want)
+		}
+	}
+
+	_, err := NewEvaluator(BuiltinScope(), &EvalOptions{MaxAlloc: 1000}).EvalSource(`format("%999999d", 1)`)
+	if !errors.Is(err, ErrAllocLimit) {
+		t.Errorf("TestFormatWidth(): got err = %v, want %v", err, ErrAllocLimit)
+	}
+}
+
+func TestRegexpCache(t *testing.T) {
+	for i := 0; i < 2*regexpCacheSize; i++ {
+		if _, err := compileRegexp("match", fmt.Sprintf("a{%d}", i)); err != nil {
+			t.Fatalf("TestRegexpCache(): got err = %v, want err = false", err)
+		}
+	}
+	if _, err := compileRegexp("match", "a{0}"); err != nil {
+		t.Fatalf("TestRegexpCache(): got err = %v, want err = false", err)
+	}
+	regexpCache.Lock()
+	defer regexpCache.Unlock()
+	if got := len(regexpCache.entries); got > regexpCacheSize || regexpCache.lru.Len() != got {
+		t.Errorf("TestRegexpCache(): got %d entries and %d in lru, want at most %d", got, regexpCache.lru.Len(), regexpCacheSize)
+	}
+	if e := regexpCache.lru.Front(); e.Value.(*regexp.Regexp).String() != "a{0}" {
+		t.Errorf("TestRegexpCache(): got most recent pattern %v, want a{0}", e.Value)
+	}
+}
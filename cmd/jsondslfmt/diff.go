@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+type edit struct {
+	op   byte // One of ' ', '-', or '+'.
+	line string
+}
+
+// diff returns a unified diff of old and new or nil if they are equal.
+func diff(oldName string, old []byte, newName string, new []byte) []byte {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+	edits := lineEdits(splitLines(old), splitLines(new))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		// Find the extent of the hunk, merging changes separated by at most 2*context lines.
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].op != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(len(edits), end+context)
+
+		oldLine, newLine := 1, 1
+		for _, e := range edits[:start] {
+			if e.op != '+' {
+				oldLine++
+			}
+			if e.op != '-' {
+				newLine++
+			}
+		}
+		var oldCount, newCount int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, e := range edits[start:end] {
+			b.WriteByte(e.op)
+			b.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return b.Bytes()
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line-- // An empty range starts at the line before.
+	}
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// splitLines splits data into lines including the trailing newlines.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEdits returns the edits transforming a into b with the fewest insertions and deletions.
+// It uses the linear space variant of Myers' O(ND) difference algorithm, splitting the
+// edit graph at the middle snake of a shortest path and recursing on both halves.
+func lineEdits(a, b []string) []edit {
+	var edits []edit
+	var compare func(a, b []string)
+	compare = func(a, b []string) {
+		for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+			edits = append(edits, edit{' ', a[0]})
+			a, b = a[1:], b[1:]
+		}
+		n := 0
+		for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+			n++
+		}
+		common := a[len(a)-n:]
+		a, b = a[:len(a)-n], b[:len(b)-n]
+		switch {
+		case len(a) == 0:
+			for _, line := range b {
+				edits = append(edits, edit{'+', line})
+			}
+		case len(b) == 0:
+			for _, line := range a {
+				edits = append(edits, edit{'-', line})
+			}
+		default:
+			x, y := middleSnake(a, b)
+			compare(a[:x], b[:y])
+			compare(a[x:], b[y:])
+		}
+		for _, line := range common {
+			edits = append(edits, edit{' ', line})
+		}
+	}
+	compare(a, b)
+	return edits
+}
+
+// middleSnake returns a point on a shortest edit path from a to b which splits it
+// into two shorter paths. The first and last lines of a and b must differ.
+//
+// The forward and reverse searches are run together, each tracking the furthest
+// reaching x on diagonal k = x-y, until they overlap in the middle.
+func middleSnake(a, b []string) (x, y int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	off := maxD
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[off+1], vb[off+1] = 0, 0
+	delta := n - m
+	front := delta%2 != 0 // Whether the forward search detects the overlap.
+	// Trim diagonals which leave the edit graph at the start or end of each search.
+	var fstart, fend, bstart, bend int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fstart; k <= d-fend; k += 2 {
+			var x int
+			if k == -d || k != d && vf[off+k-1] < vf[off+k+1] {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			switch {
+			case x > n:
+				fend += 2
+			case y > m:
+				fstart += 2
+			case front:
+				if kb := off + delta - k; kb >= 0 && kb < len(vb) && vb[kb] != -1 && x >= n-vb[kb] {
+					return x, y
+				}
+			}
+		}
+		for k := -d + bstart; k <= d-bend; k += 2 {
+			var x int
+			if k == -d || k != d && vb[off+k-1] < vb[off+k+1] {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			switch {
+			case x > n:
+				bend += 2
+			case y > m:
+				bstart += 2
+			case !front:
+				if kf := off + delta - k; kf >= 0 && kf < len(vf) && vf[kf] != -1 && vf[kf] >= n-x {
+					return vf[kf], vf[kf] - (kf - off)
+				}
+			}
+		}
+	}
+	// Unreachable for lines which differ at both ends: delete a and insert b.
+	return n, 0
+}
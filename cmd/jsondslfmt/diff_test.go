@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl"
+
+	got := string(diff("x.orig", []byte(old), "x", []byte(new)))
+
+	want := `--- x.orig
++++ x
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+\ No newline at end of file
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestDiff(): got diff:\n%s", diff)
+	}
+	if got := diff("x.orig", []byte(old), "x", []byte(old)); got != nil {
+		t.Errorf("TestDiff(): got %q for equal inputs, want nil", got)
+	}
+}
+
+func TestLineEdits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		s := make([]string, r.Intn(30))
+		for i := range s {
+			s[i] = string(rune('a' + r.Intn(4)))
+		}
+		return s
+	}
+	for i := 0; i < 1000; i++ {
+		a, b := lines(), lines()
+		edits := lineEdits(a, b)
+
+		var gotA, gotB []string
+		common := 0
+		for _, e := range edits {
+			if e.op != '+' {
+				gotA = append(gotA, e.line)
+			}
+			if e.op != '-' {
+				gotB = append(gotB, e.line)
+			}
+			if e.op == ' ' {
+				common++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("TestLineEdits(%q, %q): got edits %v which do not transform a into b", a, b, edits)
+		}
+		if want := lcsLen(a, b); common != want {
+			t.Fatalf("TestLineEdits(%q, %q): got %d common lines, want %d", a, b, common, want)
+		}
+	}
+}
+
+// lcsLen returns the length of the longest common subsequence of a and b.
+func lcsLen(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,165 @@
+// Command jsondslfmt formats jsondsl source files.
+//
+// Without an explicit path, it processes the standard input. Given a file,
+// it operates on that file; given a directory, it operates on all .jsondsl
+// files in that directory, recursively. By default, jsondslfmt prints the
+// reformatted sources to standard output.
+//
+// Usage:
+//
+//	jsondslfmt [flags] [path ...]
+//
+// The flags are:
+//
+//	-d
+//		Do not print reformatted sources to standard output.
+//		If a file's formatting is different than jsondslfmt's, print diffs
+//		to standard output.
+//	-l
+//		Do not print reformatted sources to standard output.
+//		If a file's formatting is different from jsondslfmt's, print its name
+//		to standard output.
+//	-w
+//		Do not print reformatted sources to standard output.
+//		If a file's formatting is different from jsondslfmt's, overwrite it
+//		with jsondslfmt's version.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wenooij/jsondsl"
+)
+
+// options are the flags of a run.
+type options struct {
+	list, write, diffs bool
+}
+
+const ext = ".jsondsl"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs jsondslfmt with the command line arguments args and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var opts options
+	flags := flag.NewFlagSet("jsondslfmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.BoolVar(&opts.list, "l", false, "list files whose formatting differs from jsondslfmt's")
+	flags.BoolVar(&opts.write, "w", false, "write result to (source) file instead of stdout")
+	flags.BoolVar(&opts.diffs, "d", false, "display diffs instead of rewriting files")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: jsondslfmt [flags] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	exitCode := 0
+	report := func(err error) {
+		fmt.Fprintln(stderr, err)
+		exitCode = 2
+	}
+
+	if flags.NArg() == 0 {
+		if opts.write {
+			report(fmt.Errorf("error: cannot use -w with standard input"))
+		} else if err := opts.processFile("<standard input>", stdin, stdout); err != nil {
+			report(err)
+		}
+		return exitCode
+	}
+
+	for _, root := range flags.Args() {
+		info, err := os.Stat(root)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if !info.IsDir() {
+			if err := opts.processFile(root, nil, stdout); err != nil {
+				report(err)
+			}
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				report(err)
+				return nil
+			}
+			hidden := strings.HasPrefix(d.Name(), ".")
+			if d.IsDir() {
+				if hidden && path != root {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if hidden || !strings.HasSuffix(d.Name(), ext) {
+				return nil
+			}
+			if err := opts.processFile(path, nil, stdout); err != nil {
+				report(err)
+			}
+			return nil
+		})
+		if err != nil {
+			report(err)
+		}
+	}
+	return exitCode
+}
+
+// processFile formats the file at filename or, if in is not nil, the contents of in.
+func (o *options) processFile(filename string, in io.Reader, out io.Writer) error {
+	var src []byte
+	var err error
+	if in != nil {
+		src, err = io.ReadAll(in)
+	} else {
+		src, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := jsondsl.Format(src, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	if !o.list && !o.write && !o.diffs {
+		_, err := out.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if o.list {
+		fmt.Fprintln(out, filename)
+	}
+	if o.write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if o.diffs {
+		if _, err := out.Write(diff(filename+".orig", src, filename, res)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
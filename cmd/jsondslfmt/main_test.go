@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeTree writes the files to the directory dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(nil, strings.NewReader("add(1,2)"), &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("TestRunStdin(): got exit code %d (stderr %q), want 0", code, stderr.String())
+	}
+	if diff := cmp.Diff("add(1, 2)\n", stdout.String()); diff != "" {
+		t.Errorf("TestRunStdin(): got diff:\n%s", diff)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-w"}, strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Errorf("TestRunStdin(-w): got exit code %d, want 2", code)
+	}
+}
+
+func TestRunDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.jsondsl":             "add(1,2)",
+		"ok.jsondsl":            "add(1, 2)\n",
+		"sub/b.jsondsl":         "[1,2]",
+		"sub/skip.txt":          "[1,2]",
+		"sub/.c.jsondsl":        "[1,2]",
+		".git/d.jsondsl":        "[1,2]",
+		"sub/.hidden/e.jsondsl": "[1,2]",
+	})
+	a, b := filepath.Join(dir, "a.jsondsl"), filepath.Join(dir, "sub", "b.jsondsl")
+
+	for _, tc := range []struct {
+		flag string
+		want string
+	}{
+		{"-l", a + "\n" + b + "\n"},
+		{"-d", "--- " + a + ".orig\n+++ " + a + "\n@@ -1 +1 @@\n-add(1,2)\n\\ No newline at end of file\n+add(1, 2)\n" +
+			"--- " + b + ".orig\n+++ " + b + "\n@@ -1 +1 @@\n-[1,2]\n\\ No newline at end of file\n+[1, 2]\n"},
+		{"", "add(1, 2)\nadd(1, 2)\n[1, 2]\n"},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := []string{dir}
+		if tc.flag != "" {
+			args = []string{tc.flag, dir}
+		}
+		if code := run(args, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("TestRunDir(%q): got exit code %d (stderr %q), want 0", tc.flag, code, stderr.String())
+		}
+		if diff := cmp.Diff(tc.want, stdout.String()); diff != "" {
+			t.Errorf("TestRunDir(%q): got diff:\n%s", tc.flag, diff)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-w", "-l", dir}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("TestRunDir(-w): got exit code %d (stderr %q), want 0", code, stderr.String())
+	}
+	if diff := cmp.Diff(a+"\n"+b+"\n", stdout.String()); diff != "" {
+		t.Errorf("TestRunDir(-w): got diff:\n%s", diff)
+	}
+	for name, want := range map[string]string{
+		"a.jsondsl":      "add(1, 2)\n",
+		"sub/b.jsondsl":  "[1, 2]\n",
+		"sub/.c.jsondsl": "[1,2]",
+		".git/d.jsondsl": "[1,2]",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("TestRunDir(-w): got %s diff:\n%s", name, diff)
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-l", dir}, nil, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Errorf("TestRunDir(-l): got exit code %d and output %q after -w, want 0 and no output", code, stdout.String())
+	}
+}
+
+func TestRunError(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"bad.jsondsl": "add(1,"})
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-l", dir, filepath.Join(dir, "missing.jsondsl")}, nil, &stdout, &stderr)
+
+	if code != 2 {
+		t.Errorf("TestRunError(): got exit code %d, want 2", code)
+	}
+	if got := strings.Count(stderr.String(), "\n"); got != 2 {
+		t.Errorf("TestRunError(): got stderr %q, want 2 errors", stderr.String())
+	}
+}
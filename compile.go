@@ -0,0 +1,610 @@
+package jsondsl
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Program is a value compiled by Compile for repeated evaluation.
+//
+// Names are resolved when the Program is compiled. Names bound in the compile scope
+// are replaced by their values, names bound by the Program itself refer to slots of
+// a frame created for each run, and all other names are inputs supplied to Run.
+// Later changes to the compile scope are not observed by the Program and bindings
+// made by the Program do not outlive a run.
+//
+// A Program may be run by multiple goroutines simultaneously.
+type Program struct {
+	scope  *Scope
+	code   code
+	names  []string  // Names of the inputs in the order given to Run.
+	inputs []int     // Slots of the inputs in the top level frame.
+	fn     *funcInfo // Top level function.
+}
+
+// Compile compiles a value returned from a Decoder for evaluation in scope.
+// Running the Program returns the same value as Eval except as described for Program.
+// Special forms other than the builtin forms receive a local scope binding the names
+// visible to them, so bindings made by such forms are not observed by the Program.
+func Compile(scope *Scope, val any) (*Program, error) {
+	p := &Program{scope: scope, fn: &funcInfo{}}
+	c := &compiler{scope: scope, prog: p}
+	root := &block{fn: p.fn, names: make(map[string]int)}
+	c.declare(root, val)
+	code, err := c.compile(root, val)
+	if err != nil {
+		return nil, err
+	}
+	p.code = code
+	return p, nil
+}
+
+// Names returns the names of the inputs of p in the order given to Run.
+func (p *Program) Names() []string {
+	return append([]string{}, p.names...)
+}
+
+// Run runs p with the values of its inputs in the order returned by Names.
+func (p *Program) Run(env []any) (any, error) {
+	return p.RunOptions(env, nil)
+}
+
+// RunOptions is like Run but limits the resources used by the run.
+// A nil opts imposes no limits.
+func (p *Program) RunOptions(env []any, opts *EvalOptions) (any, error) {
+	if len(env) != len(p.names) {
+		return nil, newError(KindArity, NoPos, "program expects %d inputs: got %d", len(p.names), len(env))
+	}
+	f := newFrame(p.fn, nil)
+	for i, slot := range p.inputs {
+		f.slots[slot] = env[i]
+	}
+	f.scope = p.scope
+	if opts != nil {
+		f.state = newEvalState(opts)
+	}
+	if p.scope.state != f.state {
+		f.scope = p.scope.withState(f.state)
+	}
+	return p.code(f)
+}
+
+// code is compiled code which evaluates a value in a frame.
+type code func(f *frame) (any, error)
+
+// frame holds the slots of a run of a Program or a call of a compiled lambda.
+type frame struct {
+	slots  []any
+	parent *frame     // Frame of the enclosing function.
+	scope  *Scope     // Scope given to ops.
+	state  *evalState // Resources used by the run.
+}
+
+// unset is the value of a slot whose name has not yet been bound.
+type unset struct{}
+
+func newFrame(fn *funcInfo, parent *frame) *frame {
+	f := &frame{slots: make([]any, fn.nslots), parent: parent}
+	for i := range f.slots {
+		f.slots[i] = unset{}
+	}
+	return f
+}
+
+// up returns the frame of the function n levels above f.
+func (f *frame) up(n int) *frame {
+	for ; n > 0; n-- {
+		f = f.parent
+	}
+	return f
+}
+
+// call calls the op with the argument list in the manner of Evaluator.call.
+// Errors returned by the op are annotated with a Frame for op.
+func (f *frame) call(op *Op, scope *Scope, opFn any, args []any) (any, error) {
+	if err := f.state.enter(); err != nil {
+		return nil, callError(err, op)
+	}
+	defer f.state.exit()
+	var v any
+	var err error
+	switch opFn := opFn.(type) {
+	case OpFunc:
+		v, err = opFn(scope, args)
+	case StrictFunc:
+		v, err = opFn(scope, args)
+	}
+	if err != nil {
+		return nil, callError(err, op)
+	}
+	if err := f.state.allocateValue(v); err != nil {
+		return nil, callError(err, op)
+	}
+	return v, nil
+}
+
+// funcInfo describes the frame of a Program or compiled lambda.
+type funcInfo struct {
+	depth  int // Number of enclosing functions.
+	nslots int
+}
+
+// A block is a lexical scope whose names are bound in slots of the frame of fn.
+type block struct {
+	parent *block
+	fn     *funcInfo
+	names  map[string]int
+}
+
+type compiler struct {
+	scope *Scope
+	prog  *Program
+}
+
+// formNames maps the code pointers of builtin forms to their names.
+// The builtin forms are compiled rather than called.
+var formNames = func() map[uintptr]string {
+	m := make(map[uintptr]string, len(builtinForms))
+	for name, fn := range builtinForms {
+		m[reflect.ValueOf(fn).Pointer()] = name
+	}
+	return m
+}()
+
+// builtinForm returns the name of the builtin form bound to id in b, if any.
+func (c *compiler) builtinForm(b *block, id string) (string, bool) {
+	for ; b != nil; b = b.parent {
+		if _, ok := b.names[id]; ok {
+			return "", false
+		}
+	}
+	v, err := c.scope.Lookup(id)
+	if err != nil {
+		return "", false
+	}
+	fn, ok := v.(OpFunc)
+	if !ok {
+		return "", false
+	}
+	name, ok := formNames[reflect.ValueOf(fn).Pointer()]
+	return name, ok
+}
+
+// newSlot returns a new slot for id in b.
+func (c *compiler) newSlot(b *block, id string) int {
+	slot := b.fn.nslots
+	b.fn.nslots++
+	b.names[id] = slot
+	return slot
+}
+
+// declare declares the names bound by bind in v in the block b, so that they refer
+// to slots even when used before the bind is compiled, as in recursive lambdas.
+// Values of forms which create their own scope are skipped.
+func (c *compiler) declare(b *block, v any) {
+	switch v := v.(type) {
+	case *Op:
+		switch form, _ := c.builtinForm(b, v.Id); form {
+		case "do", "let", "lambda":
+			return
+		case "bind":
+			if len(v.Args) > 0 && len(v.Args[0]) == 2 {
+				if name, err := bindingName(v.Args[0][0]); err == nil {
+					if _, ok := b.names[name]; !ok {
+						c.newSlot(b, name)
+					}
+				}
+			}
+		}
+		for _, args := range v.Args {
+			for _, a := range args {
+				c.declare(b, a)
+			}
+		}
+	case *SelectOp:
+		c.declare(b, v.X)
+		for _, args := range v.Sel.Args {
+			for _, a := range args {
+				c.declare(b, a)
+			}
+		}
+	case *IndexOp:
+		c.declare(b, v.X)
+		c.declare(b, v.Index)
+	case *SliceOp:
+		c.declare(b, v.X)
+		c.declare(b, v.Low)
+		c.declare(b, v.High)
+	case []any:
+		for _, e := range v {
+			c.declare(b, e)
+		}
+	case map[any]any:
+		for k, e := range v {
+			c.declare(b, k)
+			c.declare(b, e)
+		}
+	}
+}
+
+// load returns code loading the value of id as seen from the block at.
+func (c *compiler) load(at *block, id string, pos Pos) code {
+	return c.resolve(at, at, id, pos, false)
+}
+
+// resolve returns code loading the value of id from the innermost block enclosing from.
+// A slot whose name is not yet bound falls back to the enclosing blocks as a Scope
+// would. Names which are not otherwise found are inputs of the Program unless
+// resolving such a fallback.
+func (c *compiler) resolve(at, from *block, id string, pos Pos, fallback bool) code {
+	for b := from; b != nil; b = b.parent {
+		slot, ok := b.names[id]
+		if !ok {
+			continue
+		}
+		depth := at.fn.depth - b.fn.depth
+		outer := c.resolve(at, b.parent, id, pos, true)
+		return func(f *frame) (any, error) {
+			v := f.up(depth).slots[slot]
+			if _, ok := v.(unset); ok {
+				return outer(f)
+			}
+			return v, nil
+		}
+	}
+	if v, err := c.scope.Lookup(id); err == nil {
+		return func(*frame) (any, error) { return v, nil }
+	}
+	for i, name := range c.prog.names {
+		if name == id {
+			return c.input(at, c.prog.inputs[i])
+		}
+	}
+	if fallback {
+		return func(*frame) (any, error) {
+			return nil, newError(KindName, pos, "name %q not found", id)
+		}
+	}
+	slot := c.prog.fn.nslots
+	c.prog.fn.nslots++
+	c.prog.names = append(c.prog.names, id)
+	c.prog.inputs = append(c.prog.inputs, slot)
+	return c.input(at, slot)
+}
+
+// input returns code loading the input in slot of the top level frame.
+func (c *compiler) input(at *block, slot int) code {
+	depth := at.fn.depth
+	return func(f *frame) (any, error) { return f.up(depth).slots[slot], nil }
+}
+
+// constant returns code evaluating to v.
+func constant(v any) code {
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+func (c *compiler) compile(b *block, v any) (code, error) {
+	switch v := v.(type) {
+	case nil, bool, float64, string, OpFunc, StrictFunc:
+		return constant(v), nil
+	case *Op:
+		return c.compileOp(b, v)
+	case *SelectOp:
+		return c.compileSelect(b, v)
+	case *IndexOp:
+		return c.compileIndex(b, v)
+	case *SliceOp:
+		return c.compileSlice(b, v)
+	case []any:
+		return c.compileArray(b, v)
+	case map[any]any:
+		return c.compileObject(b, v)
+	default:
+		return nil, newError(KindType, NoPos, "unexpected type %T", v)
+	}
+}
+
+// compileAll compiles each of the values vs.
+func (c *compiler) compileAll(b *block, vs []any) ([]code, error) {
+	codes := make([]code, len(vs))
+	for i, v := range vs {
+		var err error
+		if codes[i], err = c.compile(b, v); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func (c *compiler) compileOp(b *block, op *Op) (code, error) {
+	load := c.load(b, op.Id, op.Pos)
+	if len(op.Args) == 0 {
+		return func(f *frame) (any, error) {
+			if err := f.state.step(); err != nil {
+				return nil, err
+			}
+			return load(f)
+		}, nil
+	}
+	if form, ok := c.builtinForm(b, op.Id); ok && len(op.Args) == 1 {
+		body, ok, err := c.compileForm(b, form, op)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return formCode(op, body), nil
+		}
+	}
+	call, err := c.compileCall(b, op, op.Args)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		v, err := load(f)
+		if err != nil {
+			return nil, err
+		}
+		return call(f, v)
+	}, nil
+}
+
+// formCode returns code calling the compiled body of a builtin form in the manner of a call of op.
+func formCode(op *Op, body code) code {
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		if err := f.state.enter(); err != nil {
+			return nil, callError(err, op)
+		}
+		defer f.state.exit()
+		v, err := body(f)
+		if err != nil {
+			return nil, callError(err, op)
+		}
+		if err := f.state.allocateValue(v); err != nil {
+			return nil, callError(err, op)
+		}
+		return v, nil
+	}
+}
+
+// compileCall returns a function calling an op with each argument list in turn in
+// the manner of Evaluator.evalOpArgs. Special forms receive the unevaluated arguments
+// and a local scope binding the names visible in b.
+func (c *compiler) compileCall(b *block, op *Op, args [][]any) (func(f *frame, opFn any) (any, error), error) {
+	codes := make([][]code, len(args))
+	for i, as := range args {
+		var err error
+		if codes[i], err = c.compileAll(b, as); err != nil {
+			return nil, err
+		}
+	}
+	return func(f *frame, opFn any) (any, error) {
+		for i, as := range args {
+			var v any
+			var err error
+			switch opFn.(type) {
+			case OpFunc:
+				v, err = f.call(op, c.view(b, f), opFn, as)
+			case StrictFunc:
+				vs := make([]any, len(as))
+				for j, code := range codes[i] {
+					if vs[j], err = code(f); err != nil {
+						return nil, wrapError(err, fmt.Sprintf("at operator argument %d", j))
+					}
+				}
+				v, err = f.call(op, f.scope, opFn, vs)
+			default:
+				return nil, newError(KindType, op.Pos, "call of nonfunction type: %T", opFn)
+			}
+			if err != nil {
+				return nil, err
+			}
+			opFn = v
+		}
+		return opFn, nil
+	}, nil
+}
+
+// view returns a local scope binding the names visible in the block at to their values in f.
+func (c *compiler) view(at *block, f *frame) *Scope {
+	local := f.scope.LocalScope()
+	for b := at; b != nil; b = b.parent {
+		fb := f.up(at.fn.depth - b.fn.depth)
+		for name, slot := range b.names {
+			if _, ok := local.Vars[name]; ok {
+				continue
+			}
+			if v := fb.slots[slot]; v != (unset{}) {
+				local.Vars[name] = v
+			}
+		}
+	}
+	root := f.up(at.fn.depth)
+	for i, name := range c.prog.names {
+		if _, ok := local.Vars[name]; !ok {
+			local.Vars[name] = root.slots[c.prog.inputs[i]]
+		}
+	}
+	return local
+}
+
+func (c *compiler) compileSelect(b *block, s *SelectOp) (code, error) {
+	x, err := c.compile(b, s.X)
+	if err != nil {
+		return nil, err
+	}
+	var call func(*frame, any) (any, error)
+	if len(s.Sel.Args) != 0 {
+		if call, err = c.compileCall(b, s.Sel, s.Sel.Args); err != nil {
+			return nil, err
+		}
+	}
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		xv, err := x(f)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := xv.(map[any]any)
+		if !ok {
+			return nil, newError(KindType, s.Sel.Pos, "cannot select %q from %s", s.Sel.Id, TypeName(xv))
+		}
+		v, ok := m[s.Sel.Id]
+		if !ok {
+			return nil, newError(KindName, s.Sel.Pos, "member %q not found", s.Sel.Id)
+		}
+		if call == nil {
+			return v, nil
+		}
+		return call(f, v)
+	}, nil
+}
+
+func (c *compiler) compileIndex(b *block, x *IndexOp) (code, error) {
+	xc, err := c.compile(b, x.X)
+	if err != nil {
+		return nil, err
+	}
+	idxc, err := c.compile(b, x.Index)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		v, err := xc(f)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := idxc(f)
+		if err != nil {
+			return nil, wrapError(err, "in index")
+		}
+		return indexValue(v, idx, x.Pos)
+	}, nil
+}
+
+func (c *compiler) compileSlice(b *block, x *SliceOp) (code, error) {
+	xc, err := c.compile(b, x.X)
+	if err != nil {
+		return nil, err
+	}
+	var bounds [2]code
+	for i, bv := range [2]any{x.Low, x.High} {
+		if bv == nil {
+			continue
+		}
+		if bounds[i], err = c.compile(b, bv); err != nil {
+			return nil, err
+		}
+	}
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		v, err := xc(f)
+		if err != nil {
+			return nil, err
+		}
+		n, err := sliceLen(v, x.Pos)
+		if err != nil {
+			return nil, err
+		}
+		lh := [2]int{0, n}
+		for i, bc := range bounds {
+			if bc == nil {
+				continue
+			}
+			bv, err := bc(f)
+			if err != nil {
+				return nil, wrapError(err, "in slice")
+			}
+			if lh[i], err = sliceBound(bv, n, x.Pos); err != nil {
+				return nil, err
+			}
+		}
+		return sliceValue(f.state, v, lh[0], lh[1], x.Pos)
+	}, nil
+}
+
+func (c *compiler) compileArray(b *block, a []any) (code, error) {
+	codes, err := c.compileAll(b, a)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		if err := f.state.allocate(len(codes)); err != nil {
+			return nil, err
+		}
+		res := make([]any, len(codes))
+		for i, code := range codes {
+			v, err := code(f)
+			if err != nil {
+				return nil, wrapError(err, fmt.Sprintf("at array index %d", i))
+			}
+			res[i] = v
+		}
+		return res, nil
+	}, nil
+}
+
+func (c *compiler) compileObject(b *block, m map[any]any) (code, error) {
+	type member struct {
+		k      any
+		key, v code
+	}
+	members := make([]member, 0, len(m))
+	for k, v := range m {
+		key, err := c.compile(b, k)
+		if err != nil {
+			return nil, err
+		}
+		val, err := c.compile(b, v)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member{k, key, val})
+	}
+	return func(f *frame) (any, error) {
+		if err := f.state.step(); err != nil {
+			return nil, err
+		}
+		if err := f.state.allocate(len(members)); err != nil {
+			return nil, err
+		}
+		res := make(map[any]any, len(members))
+		for _, m := range members {
+			kv, err := m.key(f)
+			if err != nil {
+				return nil, wrapError(err, fmt.Sprintf("at object key %v", m.k))
+			}
+			switch kv.(type) {
+			case nil, bool, float64, string:
+			default:
+				return nil, newError(KindType, NoPos, "unhashable type %T at object key %v", kv, m.k)
+			}
+			v, err := m.v(f)
+			if err != nil {
+				return nil, wrapError(err, fmt.Sprintf("at object value %v", m.k))
+			}
+			res[kv] = v
+		}
+		return res, nil
+	}, nil
+}
@@ -0,0 +1,254 @@
+package jsondsl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// compileForm compiles the call of a builtin form with the arguments of op.
+// It reports false if the call is left to the form itself, as when the
+// arguments are invalid and the form would return an error.
+func (c *compiler) compileForm(b *block, form string, op *Op) (code, bool, error) {
+	args := op.Args[0]
+	switch form {
+	case "bind":
+		return c.compileBind(b, args)
+	case "lambda":
+		return c.compileLambda(b, args)
+	case "if":
+		if len(args) != 2 && len(args) != 3 {
+			return nil, false, nil
+		}
+		return c.compileCond(b, args, nil)
+	case "cond":
+		return c.compileCond(b, args, nil)
+	case "switch":
+		if len(args) < 1 {
+			return nil, false, nil
+		}
+		x, err := c.compile(b, args[0])
+		if err != nil {
+			return nil, false, err
+		}
+		return c.compileCond(b, args[1:], x)
+	case "and":
+		return c.compileLogic(b, args, true)
+	case "or":
+		return c.compileLogic(b, args, false)
+	case "let":
+		return c.compileLet(b, args)
+	case "do":
+		return c.compileDo(b, args)
+	default:
+		return nil, false, nil
+	}
+}
+
+// compileCond compiles pairs of conditions and values followed by an optional default.
+// If x is not nil, the value paired with the first case equal to x is taken instead.
+func (c *compiler) compileCond(b *block, args []any, x code) (code, bool, error) {
+	codes, err := c.compileAll(b, args)
+	if err != nil {
+		return nil, false, err
+	}
+	return func(f *frame) (any, error) {
+		var xv any
+		if x != nil {
+			var err error
+			if xv, err = x(f); err != nil {
+				return nil, err
+			}
+		}
+		cs := codes
+		for ; len(cs) >= 2; cs = cs[2:] {
+			v, err := cs[0](f)
+			if err != nil {
+				return nil, err
+			}
+			if x == nil && AsBool(v) || x != nil && Equal(xv, v) {
+				return cs[1](f)
+			}
+		}
+		if len(cs) == 1 {
+			return cs[0](f)
+		}
+		return nil, nil
+	}, true, nil
+}
+
+// compileLogic compiles a call of and if and is set, otherwise of or.
+func (c *compiler) compileLogic(b *block, args []any, and bool) (code, bool, error) {
+	codes, err := c.compileAll(b, args)
+	if err != nil {
+		return nil, false, err
+	}
+	return func(f *frame) (any, error) {
+		var v any = and
+		for _, code := range codes {
+			var err error
+			if v, err = code(f); err != nil {
+				return nil, err
+			}
+			if AsBool(v) != and {
+				return v, nil
+			}
+		}
+		return v, nil
+	}, true, nil
+}
+
+func (c *compiler) compileBind(b *block, args []any) (code, bool, error) {
+	if len(args) != 2 {
+		return nil, false, nil
+	}
+	name, err := bindingName(args[0])
+	if err != nil {
+		return nil, false, nil
+	}
+	val, err := c.compile(b, args[1])
+	if err != nil {
+		return nil, false, err
+	}
+	slot, ok := b.names[name]
+	if !ok {
+		slot = c.newSlot(b, name)
+	}
+	return func(f *frame) (any, error) {
+		v, err := val(f)
+		if err != nil {
+			return nil, err
+		}
+		f.slots[slot] = v
+		return nil, nil
+	}, true, nil
+}
+
+func (c *compiler) compileLambda(b *block, args []any) (code, bool, error) {
+	if len(args) == 0 {
+		return nil, false, nil
+	}
+	fn := &funcInfo{depth: b.fn.depth + 1}
+	local := &block{parent: b, fn: fn, names: make(map[string]int)}
+	params := args[:len(args)-1]
+	for _, a := range params {
+		v, ok := a.(*Op)
+		if !ok || len(v.Args) != 0 {
+			return nil, false, nil
+		}
+		c.newSlot(local, v.Id)
+	}
+	body := args[len(args)-1]
+	c.declare(local, body)
+	bc, err := c.compile(local, body)
+	if err != nil {
+		return nil, false, err
+	}
+	return func(f *frame) (any, error) {
+		return StrictFunc(func(caller *Scope, as []any) (any, error) {
+			if len(as) != len(params) {
+				return nil, newError(KindArity, NoPos, "lambda expects %d argument, found %d", len(params), len(as))
+			}
+			lf := newFrame(fn, f)
+			lf.scope, lf.state = f.scope, caller.evalState()
+			if lf.state != f.state {
+				lf.scope = f.scope.withState(lf.state)
+			}
+			copy(lf.slots, as)
+			return bc(lf)
+		}), nil
+	}, true, nil
+}
+
+func (c *compiler) compileLet(b *block, args []any) (code, bool, error) {
+	if len(args) != 2 {
+		return nil, false, nil
+	}
+	bindings, ok := args[0].(map[any]any)
+	if !ok {
+		return nil, false, nil
+	}
+	vals := make(map[string]any, len(bindings))
+	for k, v := range bindings {
+		name, err := bindingName(k)
+		if err != nil {
+			return nil, false, nil
+		}
+		vals[name] = v
+	}
+	order, err := bindingOrder(vals)
+	if err != nil {
+		return nil, false, nil
+	}
+	local := &block{parent: b, fn: b.fn, names: make(map[string]int)}
+	bound := make([]int, len(order))
+	for i, name := range order {
+		bound[i] = c.newSlot(local, name)
+	}
+	for _, name := range order {
+		c.declare(local, vals[name])
+	}
+	c.declare(local, args[1])
+	codes := make([]code, len(order))
+	for i, name := range order {
+		if codes[i], err = c.compile(local, vals[name]); err != nil {
+			return nil, false, err
+		}
+	}
+	body, err := c.compile(local, args[1])
+	if err != nil {
+		return nil, false, err
+	}
+	slots := local.slots()
+	return func(f *frame) (any, error) {
+		reset(f, slots)
+		for i, code := range codes {
+			v, err := code(f)
+			if err != nil {
+				return nil, wrapError(err, fmt.Sprintf("in let binding %q", order[i]))
+			}
+			f.slots[bound[i]] = v
+		}
+		return body(f)
+	}, true, nil
+}
+
+func (c *compiler) compileDo(b *block, args []any) (code, bool, error) {
+	local := &block{parent: b, fn: b.fn, names: make(map[string]int)}
+	for _, a := range args {
+		c.declare(local, a)
+	}
+	codes, err := c.compileAll(local, args)
+	if err != nil {
+		return nil, false, err
+	}
+	slots := local.slots()
+	return func(f *frame) (any, error) {
+		reset(f, slots)
+		var res any
+		for _, code := range codes {
+			v, err := code(f)
+			if err != nil {
+				return nil, err
+			}
+			res = v
+		}
+		return res, nil
+	}, true, nil
+}
+
+// slots returns the slots of the names of b in increasing order.
+func (b *block) slots() []int {
+	slots := make([]int, 0, len(b.names))
+	for _, slot := range b.names {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	return slots
+}
+
+// reset unsets the slots of f so that their names are not yet bound.
+func reset(f *frame, slots []int) {
+	for _, slot := range slots {
+		f.slots[slot] = unset{}
+	}
+}
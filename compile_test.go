@@ -0,0 +1,210 @@
+package jsondsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// decodeString decodes the first value in src.
+func decodeString(t testing.TB, src string) any {
+	t.Helper()
+	d := &Decoder{}
+	d.Reset(strings.NewReader(src))
+	val, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode(%q): got err = %v, want err = false", src, err)
+	}
+	return val
+}
+
+func TestCompile(t *testing.T) {
+	for _, src := range []string{
+		`[1, "a", true, null, {"a": [1, 2]}]`,
+		`add(1, mul(2, 3))`,
+		`do(bind(x, 2), bind(f, lambda(y, mul(x, y))), f(3))`,
+		`do(bind(fact, lambda(n, if(le(n, 1), 1, mul(n, fact(sub(n, 1)))))), fact(10))`,
+		`do(bind(even, lambda(n, if(eq(n, 0), true, odd(sub(n, 1))))), bind(odd, lambda(n, if(eq(n, 0), false, even(sub(n, 1))))), even(10))`,
+		`let({a: add(b, 1), b: 2, f: lambda(x, if(le(x, 0), a, f(sub(x, 1))))}, [a, b, f(3)])`,
+		`map(range(5), lambda(x, cond(lt(x, 2), "small", eq(x, 2), "two", "big")))`,
+		`[and(1, 0, 2), or(0, null, "x"), and(), or(), switch(2, 1, "one", 2, "two", "many")]`,
+		`do(bind(add1, lambda(x, add(x, 1))), [do(bind(add1, 5), add1), add1(1)])`,
+		`do(bind(o, {"a": {"b": [5, 6, 7]}, "f": lambda(x, neg(x))}), [o.a.b[1], o.a.b[-1:], o.f(2), "héllo"[1:3]])`,
+		`reduce(map(range(1, 4), lambda(x, lambda(y, mul(x, y)))), lambda(acc, f, f(acc)), 1)`,
+		`do(bind(x, 1), [do(bind(y, x), bind(x, 2), [x, y]), x])`,
+	} {
+		want, err := Eval(BuiltinScope(), decodeString(t, src))
+		if err != nil {
+			t.Fatalf("TestCompile(%q): got Eval err = %v, want err = false", src, err)
+		}
+
+		p, err := Compile(BuiltinScope(), decodeString(t, src))
+		if err != nil {
+			t.Fatalf("TestCompile(%q): got err = %v, want err = false", src, err)
+		}
+		got, err := p.Run(nil)
+		if err != nil {
+			t.Fatalf("TestCompile(%q): got Run err = %v, want err = false", src, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("TestCompile(%q): got diff:\n%s", src, diff)
+		}
+	}
+}
+
+func TestCompileInputs(t *testing.T) {
+	p, err := Compile(BuiltinScope().Freeze(), decodeString(t, `if(gt(req.size, limit), "reject", do(bind(size, req.size), format("accept %d", size)))`))
+	if err != nil {
+		t.Fatalf("TestCompileInputs(): got err = %v, want err = false", err)
+	}
+	if diff := cmp.Diff([]string{"req", "limit"}, p.Names()); diff != "" {
+		t.Errorf("TestCompileInputs(): got names diff:\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		size float64
+		want any
+	}{
+		{10, "accept 10"},
+		{1000, "reject"},
+	} {
+		got, err := p.Run([]any{map[any]any{"size": tc.size}, float64(100)})
+		if err != nil {
+			t.Fatalf("TestCompileInputs(%v): got err = %v, want err = false", tc.size, err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("TestCompileInputs(%v): got diff:\n%s", tc.size, diff)
+		}
+	}
+
+	if _, err := p.Run(nil); err == nil {
+		t.Errorf("TestCompileInputs(): got Run(nil) err = nil, want arity error")
+	}
+}
+
+func TestCompileForm(t *testing.T) {
+	scope := BuiltinScope()
+	scope.BindForm("quote", func(scope *Scope, args []any) (any, error) {
+		return args[0].(*Op).Id, nil
+	})
+	scope.BindForm("twice", func(scope *Scope, args []any) (any, error) {
+		v, err := Eval(scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []any{v, v}, nil
+	})
+	p, err := Compile(scope, decodeString(t, `do(bind(x, add(n, 1)), [quote(x), twice(x), twice(n)])`))
+	if err != nil {
+		t.Fatalf("TestCompileForm(): got err = %v, want err = false", err)
+	}
+
+	got, err := p.Run([]any{float64(1)})
+
+	wantErr := false
+	want := []any{"x", []any{float64(2), float64(2)}, []any{float64(1), float64(1)}}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestCompileForm(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestCompileForm(): got diff:\n%s", diff)
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	for _, tc := range []struct {
+		src   string
+		kind  ErrorKind
+		msg   string
+		pos   Pos
+		stack []string
+	}{
+		{`add(1, "2")`, KindType, "add expects number in argument 1: found string", 0, []string{"add"}},
+		{`if(true, [1][2])`, KindEval, "index 2 out of range with length 1", 12, []string{"if"}},
+		{`do(bind(f, lambda(x, div(x, "a"))), f(1))`, KindType, "div expects number in argument 1: found string", 21, []string{"div", "f", "do"}},
+		{`if(true)`, KindArity, "if expects 2 or 3 arguments: got 1", 0, []string{"if"}},
+		{`do(x, bind(x, 1))`, KindName, `name "x" not found`, 3, []string{"do"}},
+		{`{"a": 1}.b`, KindName, `member "b" not found`, 9, nil},
+	} {
+		p, err := Compile(BuiltinScope(), decodeString(t, tc.src))
+		if err != nil {
+			t.Fatalf("TestCompileError(%q): got err = %v, want err = false", tc.src, err)
+		}
+		_, err = p.Run(make([]any, len(p.Names())))
+		var got *Error
+		if !errors.As(err, &got) {
+			t.Errorf("TestCompileError(%q): got err = %v, want *Error", tc.src, err)
+			continue
+		}
+		if got.Kind != tc.kind || got.Msg != tc.msg || got.Pos != tc.pos {
+			t.Errorf("TestCompileError(%q): got err = %q (kind %v, pos %d), want %q (kind %v, pos %d)", tc.src, got.Msg, got.Kind, got.Pos, tc.msg, tc.kind, tc.pos)
+		}
+		var stack []string
+		for _, f := range got.Stack {
+			stack = append(stack, f.Name)
+		}
+		if diff := cmp.Diff(tc.stack, stack); diff != "" {
+			t.Errorf("TestCompileError(%q): got stack diff:\n%s", tc.src, diff)
+		}
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	p, err := Compile(BuiltinScope(), decodeString(t, `do(bind(f, lambda(x, f(x))), f(1))`))
+	if err != nil {
+		t.Fatalf("TestCompileLimits(): got err = %v, want err = false", err)
+	}
+	for _, tc := range []struct {
+		opts EvalOptions
+		want error
+	}{
+		{EvalOptions{MaxSteps: 100}, ErrStepLimit},
+		{EvalOptions{MaxDepth: 50}, ErrDepthLimit},
+	} {
+		_, err := p.RunOptions(nil, &tc.opts)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("TestCompileLimits(%+v): got err = %v, want %v", tc.opts, err, tc.want)
+		}
+	}
+}
+
+const benchmarkSrc = `let({
+	score: reduce(map(items, lambda(x, mul(x.price, x.qty))), lambda(a, b, add(a, b)), 0)
+}, cond(gt(score, 100), "high", gt(score, 10), "medium", "low"))`
+
+var benchmarkItems = []any{
+	map[any]any{"price": float64(3), "qty": float64(2)},
+	map[any]any{"price": float64(10), "qty": float64(4)},
+	map[any]any{"price": float64(1), "qty": float64(9)},
+}
+
+func BenchmarkEval(b *testing.B) {
+	val := decodeString(b, benchmarkSrc)
+	scope := BuiltinScope().Freeze()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		local := scope.LocalScope()
+		local.Bind("items", benchmarkItems)
+		if _, err := Eval(local, val); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramRun(b *testing.B) {
+	p, err := Compile(BuiltinScope().Freeze(), decodeString(b, benchmarkSrc))
+	if err != nil {
+		b.Fatal(err)
+	}
+	env := []any{benchmarkItems}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Run(env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
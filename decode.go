@@ -1,8 +1,6 @@
 package jsondsl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
 	"strconv"
 
@@ -12,43 +10,165 @@ import (
 type Op struct {
 	Id   string
 	Args [][]any
+	Pos  Pos // Pos of Id in the source. Ops built by hand should use NoPos.
+}
+
+// SelectOp is a decoded selector of the member Sel.Id from the value X.
+// If Sel has arguments the selected member is called with them.
+type SelectOp struct {
+	X   any
+	Sel *Op
+}
+
+// IndexOp is a decoded index of the value X.
+type IndexOp struct {
+	X     any
+	Index any
+	Pos   Pos // Pos of the '['.
+}
+
+// SliceOp is a decoded slice of the value X.
+// A nil Low or High denotes the start or end of X respectively.
+type SliceOp struct {
+	X         any
+	Low, High any
+	Pos       Pos // Pos of the '['.
 }
 
 type Decoder struct {
 	*bufiog.Reader[tokenPos]
+
+	file  *File
+	eval  *Evaluator
+	depth int // Nesting depth of the value being decoded.
 }
 
+// maxNesting limits the nesting depth of decoded values.
+const maxNesting = 10000
+
 func (d *Decoder) Reset(src io.Reader) {
-	t := &Tokenizer{}
-	sc := bufio.NewScanner(src)
-	sc.Split(t.SplitFunc)
-	d.Reader = bufiog.NewReaderSize(&tokenReader{
-		t:  t,
-		sc: sc,
-	}, 64)
+	d.ResetFile(nil, src)
+}
+
+// ResetFile resets the Decoder to read from src.
+// If f is not nil, it is used to record positions and line starts of src.
+func (d *Decoder) ResetFile(f *File, src io.Reader) {
+	d.file = f
+	d.depth = 0
+	d.Reader = newTokenReader(f, src, nil)
 }
 
 func (d *Decoder) consumeToken(t Token) (Pos, error) {
 	e, err := d.ReadElem()
 	if err != nil {
+		if err == io.EOF {
+			return NoPos, io.ErrUnexpectedEOF
+		}
 		return NoPos, err
 	}
 	if e.Token != t {
-		return NoPos, fmt.Errorf("expected token %s (found %s)", t, e.Token)
+		return NoPos, syntaxError(e, "expected token %s (found %s)", t, e.Token)
 	}
 	return e.Pos, nil
 }
 
 // Decode a value but returns EOF if no value exists.
+// Other errors are returned as an *Error.
 func (d *Decoder) Decode() (any, error) {
 	if _, err := d.Peek(1); err == io.EOF {
 		return nil, io.EOF
 	}
-	return d.decodeValue()
+	v, err := d.decodeValue()
+	if err != nil {
+		return nil, resolveError(err, d.file)
+	}
+	return v, nil
 }
 
-// decodeOptValue deocdes a value otherwise returns UnexpectedEOF.
+// decodeValue decodes a value otherwise returns UnexpectedEOF.
 func (d *Decoder) decodeValue() (any, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxNesting {
+		pos := NoPos
+		if es, _ := d.Peek(1); len(es) > 0 {
+			pos = es[0].Pos
+		}
+		return nil, newError(KindSyntax, pos, "exceeded max nesting depth %d", maxNesting)
+	}
+	v, err := d.decodeOperand()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodePostfix(v)
+}
+
+// decodePostfix decodes any selectors, indices, and slices following the value x.
+// An index or slice must immediately follow x so that it is not confused with an array.
+func (d *Decoder) decodePostfix(x any) (any, error) {
+	for {
+		es, err := d.Peek(1)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		switch {
+		case len(es) == 0:
+			return x, nil
+		case es[0].Token == TokenDot:
+			d.Discard(1)
+			sel, err := d.decodeOperator()
+			if err != nil {
+				return nil, wrapError(err, "in selector")
+			}
+			x = &SelectOp{X: x, Sel: sel}
+		case es[0].Token == TokenLBrack && !es[0].Space:
+			if x, err = d.decodeIndex(x); err != nil {
+				return nil, err
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// decodeIndex decodes an index or slice of x.
+func (d *Decoder) decodeIndex(x any) (any, error) {
+	lb, err := d.consumeToken(TokenLBrack)
+	if err != nil {
+		return nil, wrapError(err, "at start of index")
+	}
+	var low any
+	if !d.peekToken(TokenColon) {
+		if low, err = d.decodeValue(); err != nil {
+			return nil, wrapError(err, "in index")
+		}
+		if !d.peekToken(TokenColon) {
+			if _, err := d.consumeToken(TokenRBrack); err != nil {
+				return nil, wrapError(err, "at end of index")
+			}
+			return &IndexOp{X: x, Index: low, Pos: lb}, nil
+		}
+	}
+	d.Discard(1)
+	var high any
+	if !d.peekToken(TokenRBrack) {
+		if high, err = d.decodeValue(); err != nil {
+			return nil, wrapError(err, "in slice")
+		}
+	}
+	if _, err := d.consumeToken(TokenRBrack); err != nil {
+		return nil, wrapError(err, "at end of slice")
+	}
+	return &SliceOp{X: x, Low: low, High: high, Pos: lb}, nil
+}
+
+// peekToken reports whether the next token is t.
+func (d *Decoder) peekToken(t Token) bool {
+	es, _ := d.Peek(1)
+	return len(es) > 0 && es[0].Token == t
+}
+
+func (d *Decoder) decodeOperand() (any, error) {
 	es, err := d.Peek(1)
 	if err != nil {
 		if err == io.EOF {
@@ -58,9 +178,9 @@ func (d *Decoder) decodeValue() (any, error) {
 	}
 	switch e := es[0]; e.Token {
 	case TokenInvalid:
-		return nil, fmt.Errorf("invalid token returned during scan")
-	case TokenColon, TokenComma, TokenLParen, TokenRParen, TokenRBrace, TokenRBrack:
-		return nil, fmt.Errorf("unexpected token %s at beginning of Value", e.Token)
+		return nil, syntaxError(e, "invalid token returned during scan")
+	case TokenColon, TokenComma, TokenDot, TokenLParen, TokenRParen, TokenRBrace, TokenRBrack:
+		return nil, syntaxError(e, "unexpected token %s at beginning of Value", e.Token)
 	case TokenLBrace:
 		object, err := d.decodeObject()
 		if err != nil {
@@ -86,7 +206,7 @@ func (d *Decoder) decodeValue() (any, error) {
 		d.Discard(1)
 		v, err := strconv.ParseFloat(e.Text, 64)
 		if err != nil {
-			return nil, err
+			return nil, &Error{Kind: KindSyntax, Pos: e.Pos, Token: e.Token, Msg: err.Error(), Err: err}
 		}
 		return v, nil
 	case TokenIdent:
@@ -98,17 +218,17 @@ func (d *Decoder) decodeValue() (any, error) {
 	case TokenString:
 		s, err := d.decodeString()
 		if err != nil {
-			return nil, fmt.Errorf("%v at string", err)
+			return nil, wrapError(err, "at string")
 		}
 		return s, nil
 	default:
-		return nil, fmt.Errorf("unknown token %s returned during scan", e.Token)
+		return nil, syntaxError(e, "unknown token %s returned during scan", e.Token)
 	}
 }
 
 func (d *Decoder) decodeArray() ([]any, error) {
 	if _, err := d.consumeToken(TokenLBrack); err != nil {
-		return nil, fmt.Errorf("%v at start of array", err)
+		return nil, wrapError(err, "at start of array")
 	}
 	var elems []any
 	if err := decodeList(d, TokenRBrack, func() error {
@@ -119,17 +239,17 @@ func (d *Decoder) decodeArray() ([]any, error) {
 		elems = append(elems, v)
 		return nil
 	}); err != nil {
-		return nil, fmt.Errorf("%v in array", err)
+		return nil, wrapError(err, "in array")
 	}
 	if _, err := d.consumeToken(TokenRBrack); err != nil {
-		return nil, fmt.Errorf("%v at end of array", err)
+		return nil, wrapError(err, "at end of array")
 	}
 	return elems, nil
 }
 
 func (d *Decoder) decodeObject() (map[any]any, error) {
 	if _, err := d.consumeToken(TokenLBrace); err != nil {
-		return nil, fmt.Errorf("%v at beginning of object", err)
+		return nil, wrapError(err, "at beginning of object")
 	}
 	var dst map[any]any
 	if err := decodeList(d, TokenRBrace, func() error {
@@ -138,10 +258,10 @@ func (d *Decoder) decodeObject() (map[any]any, error) {
 		}
 		return d.decodeMember(dst)
 	}); err != nil {
-		return nil, fmt.Errorf("%v in object", err)
+		return nil, wrapError(err, "in object")
 	}
 	if _, err := d.consumeToken(TokenRBrace); err != nil {
-		return nil, fmt.Errorf("%v at end of object", err)
+		return nil, wrapError(err, "at end of object")
 	}
 	return dst, nil
 }
@@ -149,49 +269,55 @@ func (d *Decoder) decodeObject() (map[any]any, error) {
 func (d *Decoder) decodeString() (string, error) {
 	e, err := d.ReadElem()
 	if err != nil {
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
 		return "", err
 	}
 	if e.Token != TokenString {
-		return "", fmt.Errorf("expected token %s (found %s)", TokenString, e.Token)
+		return "", syntaxError(e, "expected token %s (found %s)", TokenString, e.Token)
 	}
 	s, err := strconv.Unquote(e.Text)
 	if err != nil {
-		return "", err
+		return "", &Error{Kind: KindSyntax, Pos: e.Pos, Token: e.Token, Msg: err.Error(), Err: err}
 	}
 	return s, nil
 }
 
-func (d *Decoder) decodeId() (string, error) {
+func (d *Decoder) decodeId() (string, Pos, error) {
 	e, err := d.ReadElem()
 	if err != nil {
-		return "", err
+		if err == io.EOF {
+			return "", NoPos, io.ErrUnexpectedEOF
+		}
+		return "", NoPos, err
 	}
 	if e.Token != TokenIdent {
-		return "", fmt.Errorf("expected token %s (found %s)", TokenIdent, e.Token)
+		return "", NoPos, syntaxError(e, "expected token %s (found %s)", TokenIdent, e.Token)
 	}
-	return e.Text, nil
+	return e.Text, e.Pos, nil
 }
 
 func (d *Decoder) decodeMember(dst map[any]any) error {
 	key, err := d.decodeValue()
 	if err != nil {
-		return fmt.Errorf("%v at member key", err)
+		return wrapError(err, "at member key")
 	}
 	if _, err := d.consumeToken(TokenColon); err != nil {
-		return fmt.Errorf("%v in object member", err)
+		return wrapError(err, "in object member")
 	}
 	value, err := d.decodeValue()
 	if err != nil {
-		return fmt.Errorf("%v at member Value", err)
+		return wrapError(err, "at member Value")
 	}
 	dst[key] = value
 	return nil
 }
 
 func (d *Decoder) decodeOperator() (*Op, error) {
-	id, err := d.decodeId()
+	id, pos, err := d.decodeId()
 	if err != nil {
-		return nil, fmt.Errorf("%v at start of operator", err)
+		return nil, wrapError(err, "at start of operator")
 	}
 	var opArgs [][]any
 	for {
@@ -208,12 +334,12 @@ func (d *Decoder) decodeOperator() (*Op, error) {
 		}
 		opArgs = append(opArgs, args)
 	}
-	return &Op{Id: id, Args: opArgs}, nil
+	return &Op{Id: id, Args: opArgs, Pos: pos}, nil
 }
 
 func (d *Decoder) decodeOperatorArgs() ([]any, error) {
 	if _, err := d.consumeToken(TokenLParen); err != nil {
-		return nil, fmt.Errorf("%v at start of operator arguments", err)
+		return nil, wrapError(err, "at start of operator arguments")
 	}
 	var args []any
 	if err := decodeList(d, TokenRParen, func() error {
@@ -224,10 +350,10 @@ func (d *Decoder) decodeOperatorArgs() ([]any, error) {
 		args = append(args, v)
 		return nil
 	}); err != nil {
-		return nil, fmt.Errorf("%v at operator arguments", err)
+		return nil, wrapError(err, "at operator arguments")
 	}
 	if _, err := d.consumeToken(TokenRParen); err != nil {
-		return nil, fmt.Errorf("%v at end of operator", err)
+		return nil, wrapError(err, "at end of operator")
 	}
 	return args, nil
 }
@@ -251,7 +377,7 @@ func decodeList(d *Decoder, delim Token, decodeFn func() error) error {
 		}
 		if !first {
 			if es[0].Token != TokenComma {
-				return fmt.Errorf("expected token %s (found %s)", TokenComma, es[0].Token)
+				return syntaxError(es[0], "expected token %s (found %s)", TokenComma, es[0].Token)
 			}
 			d.Discard(1)
 			es, err = d.Peek(1)
@@ -53,7 +53,7 @@ func TestDecodeOperator(t *testing.T) {
 	got, err := d.Decode()
 
 	wantErr := false
-	want := &Op{Id: "op", Args: [][]any{nil}}
+	want := &Op{Id: "op", Args: [][]any{nil}, Pos: 0}
 
 	gotErr := err != nil
 	if gotErr != wantErr {
@@ -95,9 +95,10 @@ func TestDecodeEverythingArray(t *testing.T) {
 		`"abc"`,
 		[]any(nil),
 		map[any]any(nil),
-		&Op{Id: "id"},
+		&Op{Id: "id", Pos: 76},
 		&Op{
-			Id: "add",
+			Id:  "add",
+			Pos: 82,
 			Args: [][]any{
 				{
 					float64(1),
@@ -106,11 +107,12 @@ func TestDecodeEverythingArray(t *testing.T) {
 			},
 		},
 		&Op{
-			Id: "lambda",
+			Id:  "lambda",
+			Pos: 94,
 			Args: [][]any{
 				{
-					&Op{Id: "x"},
-					&Op{Id: "x"},
+					&Op{Id: "x", Pos: 101},
+					&Op{Id: "x", Pos: 103},
 				},
 				nil,
 			},
@@ -125,3 +127,43 @@ func TestDecodeEverythingArray(t *testing.T) {
 		t.Errorf("TestParse(): got diff:\n%s", diff)
 	}
 }
+
+func TestDecodeComments(t *testing.T) {
+	input := `// doc
+	{ /* key */ "a": 1, // one
+	}`
+
+	d := &Decoder{}
+	d.Reset(strings.NewReader(input))
+	got, err := d.Decode()
+
+	wantErr := false
+	want := map[any]any{"a": float64(1)}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestParse(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestParse(): got diff:\n%s", diff)
+	}
+}
+
+func TestDecodeStringEscapes(t *testing.T) {
+	input := `["a\\", "\"b\"", "c\\\"d"]`
+
+	d := &Decoder{}
+	d.Reset(strings.NewReader(input))
+	got, err := d.Decode()
+
+	wantErr := false
+	want := []any{`a\`, `"b"`, `c\"d`}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestDecodeStringEscapes(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestDecodeStringEscapes(): got diff:\n%s", diff)
+	}
+}
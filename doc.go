@@ -0,0 +1,15 @@
+// Package jsondsl implements a JSON DSL which adds identifiers and operators to JSON.
+//
+// The grammar of the DSL is given in examples/jsondsl.g. Values are decoded by a Decoder,
+// evaluated by an Evaluator against a Scope of bound names, and encoded by Marshal.
+//
+// Values may be followed by selectors, indices, and slices:
+//
+//	o.a.f(1)
+//	xs[0]
+//	s[1:3]
+//
+// An index or slice must immediately follow its value. Whitespace or a comment before
+// "[" ends the value, so that "x [1]" decodes as the ident x followed by the array [1].
+// This allows a sequence of statements such as "bind(x, 1) [x]" to end with an array.
+package jsondsl
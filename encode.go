@@ -0,0 +1,453 @@
+package jsondsl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Marshal returns the DSL encoding of v.
+//
+// Marshal accepts the values produced by a Decoder or Evaluator, namely nil, bool,
+// float64, string, []any, map[any]any, and *Op, as well as other Go values which are
+// converted as described by MarshalValue.
+func Marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	e := &encoder{buf: &b}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// MarshalIndent is like Marshal but places each array element, object member,
+// and operator argument on a new line beginning with prefix followed by one or
+// more copies of indent according to the nesting.
+func MarshalIndent(v any, prefix, indent string) ([]byte, error) {
+	var b bytes.Buffer
+	e := &encoder{buf: &b, prefix: prefix, indent: indent}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// An Encoder writes DSL values to an output stream.
+type Encoder struct {
+	w      io.Writer
+	prefix string
+	indent string
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded value
+// as if indented by MarshalIndent.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.prefix = prefix
+	enc.indent = indent
+}
+
+// Encode writes the DSL encoding of v to the stream followed by a newline.
+func (enc *Encoder) Encode(v any) error {
+	var b bytes.Buffer
+	e := &encoder{buf: &b, prefix: enc.prefix, indent: enc.indent}
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	b.WriteByte('\n')
+	_, err := enc.w.Write(b.Bytes())
+	return err
+}
+
+// MarshalValue converts the Go value v to a DSL value consisting only of nil, bool,
+// float64, string, []any, map[any]any, and *Op.
+//
+// Numbers are converted to float64, slices and arrays to []any, and maps and structs
+// to map[any]any. Struct fields are named by their "jsondsl" tag or field name and
+// fields tagged with "omitempty" are omitted if empty. Pointers and interfaces are
+// converted to the value they point to or nil.
+func MarshalValue(v any) (any, error) {
+	return marshalValue(reflect.ValueOf(v))
+}
+
+func marshalValue(rv reflect.Value) (any, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	switch v := rv.Interface().(type) {
+	case *Op, *SelectOp, *IndexOp, *SliceOp:
+		return v, nil
+	case []any:
+		if v == nil {
+			return []any(nil), nil
+		}
+	case map[any]any:
+		if v == nil {
+			return map[any]any(nil), nil
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return marshalValue(rv.Elem())
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		fallthrough
+	case reflect.Array:
+		a := make([]any, rv.Len())
+		for i := range a {
+			v, err := marshalValue(rv.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("%v at array index %d", err, i)
+			}
+			a[i] = v
+		}
+		return a, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		m := make(map[any]any, rv.Len())
+		for it := rv.MapRange(); it.Next(); {
+			k, err := marshalValue(it.Key())
+			if err != nil {
+				return nil, fmt.Errorf("%v at object key %v", err, it.Key())
+			}
+			switch k.(type) {
+			case nil, bool, float64, string, *Op:
+			default:
+				return nil, fmt.Errorf("unhashable type %s at object key %v", TypeName(k), it.Key())
+			}
+			v, err := marshalValue(it.Value())
+			if err != nil {
+				return nil, fmt.Errorf("%v at object value %v", err, it.Key())
+			}
+			m[k] = v
+		}
+		return m, nil
+	case reflect.Struct:
+		fields := cachedFields(rv.Type())
+		m := make(map[any]any, len(fields.list))
+		for _, f := range fields.list {
+			fv, ok := fieldByIndexNoAlloc(rv, f.index)
+			if !ok || f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			v, err := marshalValue(fv)
+			if err != nil {
+				return nil, fmt.Errorf("%v at object value %q", err, f.name)
+			}
+			m[f.name] = v
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", rv.Type())
+	}
+}
+
+// fieldByIndexNoAlloc returns the nested field of rv or false if an embedded struct pointer is nil.
+func fieldByIndexNoAlloc(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+type encoder struct {
+	buf    *bytes.Buffer
+	prefix string
+	indent string
+	depth  int
+}
+
+func (e *encoder) encode(v any) error {
+	val, err := MarshalValue(v)
+	if err != nil {
+		return err
+	}
+	return e.encodeValue(val)
+}
+
+func (e *encoder) newline() {
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(e.prefix)
+	for i := 0; i < e.depth; i++ {
+		e.buf.WriteString(e.indent)
+	}
+}
+
+func (e *encoder) encodeValue(v any) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteString("null")
+	case bool:
+		e.buf.WriteString(strconv.FormatBool(v))
+	case float64:
+		s, err := formatNumber(v)
+		if err != nil {
+			return err
+		}
+		e.buf.WriteString(s)
+	case string:
+		quote(e.buf, v)
+	case []any:
+		return e.encodeList('[', len(v), ']', func(i int) error {
+			if err := e.encodeValue(v[i]); err != nil {
+				return fmt.Errorf("%v at array index %d", err, i)
+			}
+			return nil
+		})
+	case map[any]any:
+		keys := make([]any, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sortKeys(keys)
+		return e.encodeList('{', len(keys), '}', func(i int) error {
+			k := keys[i]
+			if err := e.encodeValue(k); err != nil {
+				return fmt.Errorf("%v at object key %v", err, k)
+			}
+			e.buf.WriteByte(':')
+			if e.indent != "" || e.prefix != "" {
+				e.buf.WriteByte(' ')
+			}
+			if err := e.encodeValue(v[k]); err != nil {
+				return fmt.Errorf("%v at object value %v", err, k)
+			}
+			return nil
+		})
+	case *Op:
+		return e.encodeOp(v)
+	case *SelectOp:
+		if err := e.encodeValue(v.X); err != nil {
+			return err
+		}
+		e.buf.WriteByte('.')
+		return e.encodeOp(v.Sel)
+	case *IndexOp:
+		if err := e.encodeValue(v.X); err != nil {
+			return err
+		}
+		e.buf.WriteByte('[')
+		if err := e.encodeValue(v.Index); err != nil {
+			return err
+		}
+		e.buf.WriteByte(']')
+	case *SliceOp:
+		if err := e.encodeValue(v.X); err != nil {
+			return err
+		}
+		e.buf.WriteByte('[')
+		if v.Low != nil {
+			if err := e.encodeValue(v.Low); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(':')
+		if v.High != nil {
+			if err := e.encodeValue(v.High); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+func (e *encoder) encodeOp(op *Op) error {
+	if op == nil {
+		e.buf.WriteString("null")
+		return nil
+	}
+	if !isIdent(op.Id) {
+		return fmt.Errorf("invalid op id %q", op.Id)
+	}
+	e.buf.WriteString(op.Id)
+	for _, args := range op.Args {
+		if err := e.encodeList('(', len(args), ')', func(i int) error {
+			if err := e.encodeValue(args[i]); err != nil {
+				return fmt.Errorf("%v at operator argument %d", err, i)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeList encodes a generic list of n elements as seen in the object, array, and operator specs.
+// Each element is placed on a new line followed by a trailing comma when indenting.
+func (e *encoder) encodeList(open byte, n int, close byte, encodeFn func(i int) error) error {
+	e.buf.WriteByte(open)
+	if n == 0 {
+		e.buf.WriteByte(close)
+		return nil
+	}
+	indent := e.indent != "" || e.prefix != ""
+	e.depth++
+	for i := 0; i < n; i++ {
+		if indent {
+			e.newline()
+		} else if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := encodeFn(i); err != nil {
+			return err
+		}
+		if indent {
+			e.buf.WriteByte(',')
+		}
+	}
+	e.depth--
+	if indent {
+		e.newline()
+	}
+	e.buf.WriteByte(close)
+	return nil
+}
+
+// quote writes s as a string literal to buf using only the escapes of the grammar.
+// Control characters and U+007F are written as \u escapes, and invalid UTF-8 as U+FFFD.
+// Other characters, including those outside the Basic Multilingual Plane, are written as is.
+func quote(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(byte(r))
+		case r == '\b':
+			buf.WriteString(`\b`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[r>>4])
+			buf.WriteByte(hex[r&0xf])
+		case r == utf8.RuneError && size == 1:
+			buf.WriteString(`\ufffd`)
+		default:
+			buf.WriteString(s[i-size : i])
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formatNumber formats f as a valid numeric literal.
+func formatNumber(f float64) (string, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("unsupported number value %v", f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	return strconv.FormatFloat(f, format, -1, 64), nil
+}
+
+// isIdent reports whether s is a valid identifier which is not a keyword.
+func isIdent(s string) bool {
+	if s == "" || s == "null" || s == "false" || s == "true" {
+		return false
+	}
+	for i, r := range s {
+		if !('A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || r == '_' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// sortKeys sorts object keys by type then value for a deterministic encoding.
+// The type order is: null, bool, number, string, op.
+func sortKeys(keys []any) {
+	rank := func(v any) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		case string:
+			return 3
+		default:
+			return 4
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		switch a := a.(type) {
+		case bool:
+			return !a && b.(bool)
+		case float64:
+			return a < b.(float64)
+		case string:
+			return a < b.(string)
+		case *Op:
+			if b, ok := b.(*Op); ok {
+				return a.Id < b.Id
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,149 @@
+package jsondsl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestMarshal(t *testing.T) {
+	input := []any{
+		nil,
+		false,
+		1.5,
+		-1e+75,
+		"\"abc\"",
+		[]any(nil),
+		map[any]any{"b": float64(2), float64(1): true, nil: "null"},
+		&Op{Id: "x"},
+		&Op{Id: "lambda", Args: [][]any{{&Op{Id: "x"}, &Op{Id: "x"}}, nil}},
+	}
+
+	got, err := Marshal(input)
+
+	wantErr := false
+	want := `[null,false,1.5,-1e+75,"\"abc\"",[],{null:"null",1:true,"b":2},x,lambda(x,x)()]`
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestMarshal(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("TestMarshal(): got diff:\n%s", diff)
+	}
+}
+
+func TestMarshalIndentRoundTrip(t *testing.T) {
+	input := `{"tags": ["a", "b"], "f": add(1, 2)(3), "empty": {}, "timeout": 30}`
+
+	d := &Decoder{}
+	d.Reset(strings.NewReader(input))
+	val, err := d.Decode()
+	if err != nil {
+		t.Fatalf("TestMarshalIndent(): failed to decode input: %v", err)
+	}
+
+	got, err := MarshalIndent(val, "", "  ")
+
+	wantErr := false
+	want := `{
+  "empty": {},
+  "f": add(
+    1,
+    2,
+  )(
+    3,
+  ),
+  "tags": [
+    "a",
+    "b",
+  ],
+  "timeout": 30,
+}`
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestMarshalIndent(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("TestMarshalIndent(): got diff:\n%s", diff)
+	}
+
+	d.Reset(strings.NewReader(string(got)))
+	roundTrip, err := d.Decode()
+	if err != nil {
+		t.Fatalf("TestMarshalIndent(): failed to decode output: %v", err)
+	}
+	if diff := cmp.Diff(val, roundTrip, cmpopts.IgnoreFields(Op{}, "Pos")); diff != "" {
+		t.Errorf("TestMarshalIndent(): got round trip diff:\n%s", diff)
+	}
+}
+
+func TestEncodeStruct(t *testing.T) {
+	var sb strings.Builder
+	err := NewEncoder(&sb).Encode(testConfig{Name: "a", Tags: []string{"x"}, Pair: [2]bool{true}})
+
+	wantErr := false
+	want := `{"Level":0,"extra":null,"keys":null,"limits":null,"name":"a","next":null,"pair":[true,false],"ratio":0,"tags":["x"]}` + "\n"
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEncode(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, sb.String()); diff != "" {
+		t.Errorf("TestEncode(): got diff:\n%s", diff)
+	}
+}
+
+func TestMarshalSelector(t *testing.T) {
+	input := `f(x).db.get("host")`
+
+	d := &Decoder{}
+	d.Reset(strings.NewReader(input))
+	val, err := d.Decode()
+	if err != nil {
+		t.Fatalf("TestMarshalSelector(): failed to decode input: %v", err)
+	}
+
+	got, err := Marshal(val)
+
+	wantErr := false
+	want := `f(x).db.get("host")`
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestMarshalSelector(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("TestMarshalSelector(): got diff:\n%s", diff)
+	}
+}
+
+func TestMarshalStringRoundTrip(t *testing.T) {
+	input := "a\"\\/\b\f\n\r\t\x00\x1f\x7fé \U0001F600\U000E0001"
+
+	got, err := Marshal(input)
+	if err != nil {
+		t.Fatalf("TestMarshalStringRoundTrip(): got err = %v, want err = false", err)
+	}
+	want := `"a\"\\/\b\f\n\r\t\u0000\u001f\u007f` + "é \U0001F600\U000E0001" + `"`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("TestMarshalStringRoundTrip(): got diff:\n%s", diff)
+	}
+
+	d := &Decoder{}
+	d.Reset(strings.NewReader(string(got)))
+	roundTrip, err := d.Decode()
+	if err != nil {
+		t.Fatalf("TestMarshalStringRoundTrip(): failed to decode output: %v", err)
+	}
+	if diff := cmp.Diff(input, roundTrip); diff != "" {
+		t.Errorf("TestMarshalStringRoundTrip(): got round trip diff:\n%s", diff)
+	}
+
+	if got, err := Marshal("\xff"); err != nil || string(got) != `"\ufffd"` {
+		t.Errorf("TestMarshalStringRoundTrip(): got %s, %v for invalid UTF-8, want %s", got, err, `"\ufffd"`)
+	}
+}
@@ -0,0 +1,241 @@
+package jsondsl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ErrorKind classifies an Error.
+type ErrorKind int
+
+const (
+	KindUnknown       ErrorKind = iota // Unclassified error.
+	KindScan                           // Invalid input found while scanning tokens.
+	KindSyntax                         // Unexpected token found while parsing or decoding.
+	KindUnexpectedEOF                  // Input ended before a value was complete.
+	KindName                           // Name not found in scope.
+	KindType                           // Value of an unexpected type.
+	KindArity                          // Wrong number of operator arguments.
+	KindEval                           // Other evaluation error.
+	KindLimit                          // Evaluation limit exceeded or evaluation canceled.
+)
+
+var kindStr = map[ErrorKind]string{
+	KindUnknown:       "unknown error",
+	KindScan:          "scan error",
+	KindSyntax:        "syntax error",
+	KindUnexpectedEOF: "unexpected EOF",
+	KindName:          "name error",
+	KindType:          "type error",
+	KindArity:         "arity error",
+	KindEval:          "eval error",
+	KindLimit:         "limit error",
+}
+
+func (k ErrorKind) String() string {
+	if s, ok := kindStr[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// Error describes a failure to parse, decode, or evaluate a value.
+// Use errors.As to obtain an *Error from errors returned by this package.
+type Error struct {
+	Kind     ErrorKind
+	Pos      Pos      // Pos of the offending token or value, or NoPos.
+	Position Position // Position resolving Pos if a File was available.
+	Token    Token    // Offending token or TokenInvalid.
+	Msg      string
+	Context  []string // Context of the error from innermost to outermost, e.g. "in array".
+	Stack    []Frame  // Op calls in progress when evaluation failed from innermost to outermost.
+	Err      error    // Underlying error, if any.
+}
+
+// Frame describes an op call in the Stack of an Error.
+type Frame struct {
+	Name     string   // Name of the op.
+	Pos      Pos      // Pos of the op, or NoPos.
+	Position Position // Position resolving Pos if a File was available.
+}
+
+func (f Frame) String() string {
+	if f.Position.IsValid() {
+		return fmt.Sprintf("%s(...)\n\t%s", f.Name, f.Position)
+	}
+	return f.Name + "(...)"
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	if e.Position.IsValid() {
+		sb.WriteString(e.Position.String())
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Msg)
+	for _, c := range e.Context {
+		sb.WriteByte(' ')
+		sb.WriteString(c)
+	}
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Trace returns the error message followed by the Stack of op calls,
+// innermost first, in a format similar to a Go panic.
+func (e *Error) Trace() string {
+	var sb strings.Builder
+	sb.WriteString(e.Error())
+	if len(e.Stack) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, f := range e.Stack {
+		sb.WriteString("\n")
+		sb.WriteString(f.String())
+	}
+	return sb.String()
+}
+
+// newError returns a new Error without context.
+func newError(kind ErrorKind, pos Pos, format string, args ...any) *Error {
+	return &Error{Kind: kind, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// syntaxError returns a new syntax Error for the offending token e.
+func syntaxError(e tokenPos, format string, args ...any) *Error {
+	err := newError(KindSyntax, e.Pos, format, args...)
+	err.Token = e.Token
+	return err
+}
+
+// asError returns err as an *Error, wrapping it if it is not already one.
+func asError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	e := &Error{Kind: KindUnknown, Pos: NoPos, Msg: err.Error(), Err: err}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		e.Kind = KindUnexpectedEOF
+	}
+	return e
+}
+
+// maxContext limits the Context of an Error, which otherwise grows with the nesting
+// depth of the value in which the error occurred.
+const maxContext = 20
+
+// wrapError appends context to err.
+// Context beyond maxContext is elided.
+func wrapError(err error, context string) error {
+	e := asError(err)
+	switch n := len(e.Context); {
+	case n < maxContext:
+		e.Context = append(e.Context, context)
+	case n == maxContext:
+		e.Context = append(e.Context, "...")
+	}
+	return e
+}
+
+// callError annotates err with a Frame for the failed call of op.
+// The error takes the Pos of op if it has none.
+func callError(err error, op *Op) error {
+	e := asError(err)
+	if e.Pos == NoPos {
+		e.Pos = op.Pos
+	}
+	e.Stack = append(e.Stack, Frame{Name: op.Id, Pos: op.Pos})
+	return e
+}
+
+// resolveError returns err as an *Error with Position resolved using f.
+// io.EOF is returned as is to signal the end of input.
+func resolveError(err error, f *File) error {
+	if f == nil {
+		return resolvePositions(err, nil)
+	}
+	return resolvePositions(err, f.Position)
+}
+
+// resolveErrorSet is like resolveError but resolves positions of any file in fsets.
+// Positions outside of the files of fsets are left unresolved.
+func resolveErrorSet(err error, fsets ...*FileSet) error {
+	return resolvePositions(err, func(p Pos) Position {
+		for _, fset := range fsets {
+			if fset == nil {
+				continue
+			}
+			if pos := fset.Position(p); pos.IsValid() {
+				return pos
+			}
+		}
+		return Position{}
+	})
+}
+
+// resolvePositions returns err as an *Error with the Positions of the error and its
+// Stack resolved using position if not already resolved.
+func resolvePositions(err error, position func(Pos) Position) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	e := asError(err)
+	if position == nil {
+		return e
+	}
+	if e.Pos != NoPos && !e.Position.IsValid() {
+		e.Position = position(e.Pos)
+	}
+	for i := range e.Stack {
+		if fr := &e.Stack[i]; fr.Pos != NoPos && !fr.Position.IsValid() {
+			fr.Position = position(fr.Pos)
+		}
+	}
+	return e
+}
+
+// ErrorList is a list of *Errors.
+type ErrorList []*Error
+
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l ErrorList) Less(i, j int) bool {
+	if l[j].Pos == NoPos {
+		return l[i].Pos != NoPos
+	}
+	return l[i].Pos != NoPos && l[i].Pos < l[j].Pos
+}
+
+// Sort sorts the list by Pos placing errors without a Pos last.
+func (l ErrorList) Sort() { sort.Stable(l) }
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Unwrap returns the errors in the list.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Err returns an error equivalent to the list or nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
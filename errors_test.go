@@ -0,0 +1,148 @@
+package jsondsl
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseError(t *testing.T) {
+	input := `{
+	"a": [1 2],
+}`
+
+	_, err := Parse(input)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestParseError(): got err = %v, want *Error", err)
+	}
+
+	want := &Error{
+		Kind:     KindSyntax,
+		Pos:      11,
+		Position: Position{Offset: 11, Line: 2, Column: 10},
+		Token:    TokenNumber,
+		Msg:      "expected token Comma (found Number)",
+		Context:  []string{"in array", "at member Value", "in object"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestParseError(): got diff:\n%s", diff)
+	}
+	if got, want := err.Error(), "2:10: expected token Comma (found Number) in array at member Value in object"; got != want {
+		t.Errorf("TestParseError(): got error string %q, want %q", got, want)
+	}
+}
+
+func TestParseErrorUnexpectedEOF(t *testing.T) {
+	input := `[1,`
+
+	_, err := Parse(input)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestParseError(): got err = %v, want *Error", err)
+	}
+	if got.Kind != KindUnexpectedEOF || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("TestParseError(): got err = %v (kind %v), want unexpected EOF", err, got.Kind)
+	}
+}
+
+func TestErrorTruncatedSelector(t *testing.T) {
+	input := `a.`
+
+	_, parseErr := Parse(input)
+	_, evalErr := EvalSource(BuiltinScope(), input)
+	visitErr := (&Visitor{}).Visit(strings.NewReader(input))
+
+	for _, err := range []error{parseErr, evalErr, visitErr} {
+		if errors.Is(err, io.EOF) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("TestErrorTruncatedSelector(): got err = %v, want unexpected EOF", err)
+		}
+	}
+}
+
+func TestEvalSourceError(t *testing.T) {
+	input := `[1, x]`
+
+	_, err := EvalSource(BuiltinScope(), input)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestEvalSourceError(): got err = %v, want *Error", err)
+	}
+
+	want := &Error{
+		Kind:     KindName,
+		Position: Position{Offset: 4, Line: 1, Column: 5},
+		Msg:      `name "x" not found`,
+		Context:  []string{"at array index 1"},
+	}
+	// Pos depends on the files evaluated before.
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty(), cmpopts.IgnoreFields(Error{}, "Pos")); diff != "" {
+		t.Errorf("TestEvalSourceError(): got diff:\n%s", diff)
+	}
+}
+
+func TestEvalSourceErrorStack(t *testing.T) {
+	input := `bind(f, lambda(x, add(x, y)))
+bind(g, lambda(x, f(x)))
+[1, g(2)]`
+
+	_, err := EvalSource(BuiltinScope(), input)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestEvalSourceErrorStack(): got err = %v, want *Error", err)
+	}
+
+	want := `1:26: name "y" not found at operator argument 1 at array index 1
+
+f(...)
+	2:19
+g(...)
+	3:5`
+	if diff := cmp.Diff(want, got.Trace()); diff != "" {
+		t.Errorf("TestEvalSourceErrorStack(): got diff:\n%s", diff)
+	}
+}
+
+func TestEvalErrorUnknownPos(t *testing.T) {
+	op := &Op{Id: "add", Args: [][]any{{float64(1), "x"}}, Pos: NoPos}
+
+	_, err := Eval(BuiltinScope(), op)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestEvalErrorUnknownPos(): got err = %v, want *Error", err)
+	}
+	if got.Pos != NoPos {
+		t.Errorf("TestEvalErrorUnknownPos(): got Pos = %d, want NoPos", got.Pos)
+	}
+	if want := "add expects number in argument 1: found string\n\nadd(...)"; got.Trace() != want {
+		t.Errorf("TestEvalErrorUnknownPos(): got trace %q, want %q", got.Trace(), want)
+	}
+}
+
+func TestEvalErrorDecodedPos(t *testing.T) {
+	d := &Decoder{}
+	d.Reset(strings.NewReader(`nope(1)`))
+	val, err := d.Decode()
+	if err != nil {
+		t.Fatalf("TestEvalErrorDecodedPos(): got Decode err = %v, want err = false", err)
+	}
+
+	_, err = Eval(BuiltinScope(), val)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestEvalErrorDecodedPos(): got err = %v, want *Error", err)
+	}
+	if got.Kind != KindName || got.Pos != 0 {
+		t.Errorf("TestEvalErrorDecodedPos(): got err = %v (kind %v, pos %d), want name error at 0", err, got.Kind, got.Pos)
+	}
+}
@@ -1,20 +1,65 @@
 package jsondsl
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math"
 	"strings"
+	"unicode/utf8"
 )
 
+// OpFunc is an op which receives its arguments unevaluated.
+// Such ops are special forms which evaluate their arguments as needed using Eval.
+// Bind them with Scope.BindForm.
 type OpFunc = func(scope *Scope, args []any) (any, error)
 
+// StrictFunc is an op which receives its arguments already evaluated in the caller's scope.
+// Bind them with Scope.BindStrict.
+type StrictFunc func(scope *Scope, args []any) (any, error)
+
+// asStrictFunc returns the op v as a StrictFunc.
+// Special forms are called with the evaluated values which evaluate to themselves.
+func asStrictFunc(v any) (StrictFunc, bool) {
+	switch v := v.(type) {
+	case StrictFunc:
+		return v, true
+	case OpFunc:
+		return StrictFunc(v), true
+	default:
+		return nil, false
+	}
+}
+
 type Evaluator struct {
 	scope *Scope
+	state *evalState
+}
+
+// NewEvaluator returns an Evaluator which binds in scope.
+// A nil opts imposes no limits on evaluation.
+func NewEvaluator(scope *Scope, opts *EvalOptions) *Evaluator {
+	e := &Evaluator{scope: scope}
+	if opts != nil {
+		e.state = newEvalState(opts)
+	}
+	if scope.state != e.state {
+		e.scope = scope.withState(e.state)
+	}
+	return e
 }
 
 // Eval evaluates a value returned from a Decoder.
+// Eval observes the limits of the evaluation in progress in scope if any.
 func Eval(scope *Scope, val any) (any, error) {
-	return (&Evaluator{scope}).Eval(val)
+	return (&Evaluator{scope: scope, state: scope.evalState()}).Eval(val)
+}
+
+// EvalContext evaluates a value returned from a Decoder using ctx as the Context of the evaluation.
+// Evaluation is aborted with an *Error of KindLimit when ctx is done.
+// Ops observe ctx through Scope.Context.
+func EvalContext(ctx context.Context, scope *Scope, val any) (any, error) {
+	return NewEvaluator(scope, &EvalOptions{Context: ctx}).Eval(val)
 }
 
 func EvalOpFunc(scope *Scope, val any) (OpFunc, error) {
@@ -23,27 +68,48 @@ func EvalOpFunc(scope *Scope, val any) (OpFunc, error) {
 		return nil, err
 	}
 	switch v := v.(type) {
-	case *Op:
-		val, err := scope.Lookup(v.Id)
-		if err != nil {
-			return nil, err
-		}
-		if val, ok := val.(OpFunc); ok {
-			return val, nil
-		}
-		return nil, fmt.Errorf("name %q is %s not op", v.Id, TypeName(v))
+	case OpFunc:
+		return v, nil
+	case StrictFunc:
+		return func(scope *Scope, args []any) (any, error) {
+			vs, err := evalArgs(scope, args)
+			if err != nil {
+				return nil, err
+			}
+			return v(scope, vs)
+		}, nil
 	default:
-		return nil, fmt.Errorf("expected op, found %s", TypeName(v))
+		return nil, newError(KindType, NoPos, "expected op, found %s", TypeName(v))
 	}
 }
 
 // EvalSource evaluates all statements in src and returns only
 // the value of the last statement.
 // All bindings to the provided scope are retained.
+// Errors are returned as an *Error.
 func EvalSource(scope *Scope, src string) (any, error) {
+	return NewEvaluator(scope, nil).EvalSource(src)
+}
+
+// EvalSource evaluates all statements in src and returns only
+// the value of the last statement.
+// All bindings to the scope of the Evaluator are retained.
+// Errors are returned as an *Error.
+func (e *Evaluator) EvalSource(src string) (any, error) {
+	return e.EvalFile(NewFileSet(), "", src)
+}
+
+// EvalFile is like EvalSource but adds the source file to fset.
+// Errors are resolved using fset, so that errors in ops defined by other files in fset
+// are reported at their positions in those files. Files of modules loaded by a Loader
+// bound in the scope of the Evaluator are resolved as well.
+//
+// The file is added at a base which is unique among evaluated files, so that ops
+// defined by files outside of fset are never reported at positions in fset.
+func (e *Evaluator) EvalFile(fset *FileSet, filename, src string) (any, error) {
+	f := fset.AddFile(filename, reserveBase(fset.Base(), len(src)), len(src))
 	d := &Decoder{}
-	d.Reset(strings.NewReader(src))
-	e := &Evaluator{scope}
+	d.ResetFile(f, strings.NewReader(src))
 	var res any
 	for {
 		val, err := d.Decode()
@@ -55,7 +121,7 @@ func EvalSource(scope *Scope, src string) (any, error) {
 		}
 		val, err = e.Eval(val)
 		if err != nil {
-			return nil, err
+			return nil, resolveErrorSet(err, fset, e.scope.fileSet())
 		}
 		res = val
 	}
@@ -64,20 +130,30 @@ func EvalSource(scope *Scope, src string) (any, error) {
 
 func (e *Evaluator) Init() {
 	e.scope = BuiltinScope()
+	e.state = nil
 }
 
 func (e *Evaluator) Eval(v any) (any, error) {
+	if err := e.state.step(); err != nil {
+		return nil, err
+	}
 	switch v := v.(type) {
-	case nil, bool, float64, string:
+	case nil, bool, float64, string, OpFunc, StrictFunc:
 		return v, nil
 	case *Op:
 		return e.evalOp(v)
+	case *SelectOp:
+		return e.evalSelect(v)
+	case *IndexOp:
+		return e.evalIndex(v)
+	case *SliceOp:
+		return e.evalSlice(v)
 	case []any:
 		return e.evalArray(v)
 	case map[any]any:
 		return e.evalObject(v)
 	default:
-		return nil, fmt.Errorf("unexpected type %T", v)
+		return nil, newError(KindType, NoPos, "unexpected type %T", v)
 	}
 }
 
@@ -85,43 +161,92 @@ func (e *Evaluator) Eval(v any) (any, error) {
 func (e *Evaluator) evalOp(op *Op) (any, error) {
 	v, err := e.scope.Lookup(op.Id)
 	if err != nil {
+		asError(err).Pos = op.Pos
 		return nil, err
 	}
-	switch v := v.(type) {
-	case OpFunc:
+	switch v.(type) {
+	case OpFunc, StrictFunc:
 		if len(op.Args) == 0 {
 			return v, nil
 		}
-		return e.evalOpArgs(v, op.Args)
+		return e.evalOpArgs(op, v, op.Args)
 	default:
 		if len(op.Args) != 0 {
-			return nil, fmt.Errorf("call of nonfunction type: %T", v)
+			return nil, newError(KindType, op.Pos, "call of nonfunction type: %T", v)
 		}
 		return v, nil
 	}
 }
 
-func (e *Evaluator) evalOpArgs(opFn OpFunc, args [][]any) (any, error) {
-	v, err := opFn(e.scope, args[0])
+// evalOpArgs calls the op with each argument list in turn.
+// Arguments are evaluated first if the op is a StrictFunc.
+// Errors returned by the op are annotated with a Frame for op.
+func (e *Evaluator) evalOpArgs(op *Op, opFn any, args [][]any) (any, error) {
+	as := args[0]
+	switch opFn.(type) {
+	case OpFunc:
+	case StrictFunc:
+		var err error
+		if as, err = e.evalArgs(as); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, newError(KindType, op.Pos, "call of nonfunction type: %T", opFn)
+	}
+	v, err := e.call(opFn, as)
 	if err != nil {
-		return nil, err
+		return nil, callError(err, op)
 	}
 	if len(args) == 1 {
 		return v, nil
 	}
-	next, ok := v.(OpFunc)
-	if !ok {
-		return nil, fmt.Errorf("call of nonfunction type: %T", v)
+	return e.evalOpArgs(op, v, args[1:])
+}
+
+// call calls the op with the argument list counting the nested call.
+func (e *Evaluator) call(opFn any, args []any) (any, error) {
+	if err := e.state.enter(); err != nil {
+		return nil, err
+	}
+	defer e.state.exit()
+	var v any
+	var err error
+	switch opFn := opFn.(type) {
+	case OpFunc:
+		v, err = opFn(e.scope, args)
+	case StrictFunc:
+		v, err = opFn(e.scope, args)
 	}
-	return e.evalOpArgs(next, args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if err := e.state.allocateValue(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (e *Evaluator) evalArgs(args []any) ([]any, error) {
+	vs := make([]any, len(args))
+	for i, a := range args {
+		v, err := e.Eval(a)
+		if err != nil {
+			return nil, wrapError(err, fmt.Sprintf("at operator argument %d", i))
+		}
+		vs[i] = v
+	}
+	return vs, nil
 }
 
 func (e *Evaluator) evalArray(a []any) ([]any, error) {
+	if err := e.state.allocate(len(a)); err != nil {
+		return nil, err
+	}
 	aCopy := make([]any, len(a))
 	for i, v := range a {
 		v, err := e.Eval(v)
 		if err != nil {
-			return nil, fmt.Errorf("%v at array index %d", err, i)
+			return nil, wrapError(err, fmt.Sprintf("at array index %d", i))
 		}
 		aCopy[i] = v
 	}
@@ -129,23 +254,192 @@ func (e *Evaluator) evalArray(a []any) ([]any, error) {
 }
 
 func (e *Evaluator) evalObject(a map[any]any) (map[any]any, error) {
+	if err := e.state.allocate(len(a)); err != nil {
+		return nil, err
+	}
 	aCopy := make(map[any]any, len(a))
 	for k, v := range a {
 		kv, err := e.Eval(k)
 		if err != nil {
-			return nil, fmt.Errorf("%v at object key %v", err, k)
+			return nil, wrapError(err, fmt.Sprintf("at object key %v", k))
 		}
 		// Check whether kv is hashable.
 		switch kv.(type) {
 		case nil, bool, float64, string:
 		default:
-			return nil, fmt.Errorf("unhashable type %T at object key %v", kv, k)
+			return nil, newError(KindType, NoPos, "unhashable type %T at object key %v", kv, k)
 		}
 		v, err := e.Eval(v)
 		if err != nil {
-			return nil, fmt.Errorf("%v at object value %v", err, k)
+			return nil, wrapError(err, fmt.Sprintf("at object value %v", k))
 		}
 		aCopy[kv] = v
 	}
 	return aCopy, nil
 }
+
+// evalSelect evaluates the member of an object selected by s
+// and calls it if the selector has arguments.
+func (e *Evaluator) evalSelect(s *SelectOp) (any, error) {
+	x, err := e.Eval(s.X)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := x.(map[any]any)
+	if !ok {
+		return nil, newError(KindType, s.Sel.Pos, "cannot select %q from %s", s.Sel.Id, TypeName(x))
+	}
+	v, ok := m[s.Sel.Id]
+	if !ok {
+		return nil, newError(KindName, s.Sel.Pos, "member %q not found", s.Sel.Id)
+	}
+	if len(s.Sel.Args) == 0 {
+		return v, nil
+	}
+	return e.evalOpArgs(s.Sel, v, s.Sel.Args)
+}
+
+// evalIndex evaluates the element of an array or string, or the member of an object.
+// Negative indices count from the end of the array or string.
+func (e *Evaluator) evalIndex(x *IndexOp) (any, error) {
+	v, err := e.Eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := e.Eval(x.Index)
+	if err != nil {
+		return nil, wrapError(err, "in index")
+	}
+	return indexValue(v, idx, x.Pos)
+}
+
+// indexValue returns the element of an array or string, or the member of an object at idx.
+func indexValue(v, idx any, pos Pos) (any, error) {
+	switch v := v.(type) {
+	case []any:
+		i, err := index(idx, len(v), pos)
+		if err != nil {
+			return nil, err
+		}
+		return v[i], nil
+	case string:
+		rs := []rune(v)
+		i, err := index(idx, len(rs), pos)
+		if err != nil {
+			return nil, err
+		}
+		return string(rs[i]), nil
+	case map[any]any:
+		switch idx.(type) {
+		case nil, bool, float64, string:
+		default:
+			return nil, newError(KindType, pos, "unhashable type %s in index", TypeName(idx))
+		}
+		m, ok := v[idx]
+		if !ok {
+			return nil, newError(KindName, pos, "key %#v not found", idx)
+		}
+		return m, nil
+	default:
+		return nil, newError(KindType, pos, "cannot index %s", TypeName(v))
+	}
+}
+
+// evalSlice evaluates the elements of an array or the characters of a string from Low up to
+// but not including High. Negative bounds count from the end of the array or string.
+func (e *Evaluator) evalSlice(x *SliceOp) (any, error) {
+	v, err := e.Eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+	n, err := sliceLen(v, x.Pos)
+	if err != nil {
+		return nil, err
+	}
+	bounds := [2]int{0, n}
+	for i, b := range [2]any{x.Low, x.High} {
+		if b == nil {
+			continue
+		}
+		bv, err := e.Eval(b)
+		if err != nil {
+			return nil, wrapError(err, "in slice")
+		}
+		if bounds[i], err = sliceBound(bv, n, x.Pos); err != nil {
+			return nil, err
+		}
+	}
+	return sliceValue(e.state, v, bounds[0], bounds[1], x.Pos)
+}
+
+// sliceLen returns the length of the array or string v to be sliced.
+func sliceLen(v any, pos Pos) (int, error) {
+	switch v := v.(type) {
+	case []any:
+		return len(v), nil
+	case string:
+		return utf8.RuneCountInString(v), nil
+	default:
+		return 0, newError(KindType, pos, "cannot slice %s", TypeName(v))
+	}
+}
+
+// sliceBound returns the slice bound bv into a sequence of length n.
+// Negative bounds count from the end of the sequence.
+func sliceBound(bv any, n int, pos Pos) (int, error) {
+	b, err := integer(bv, pos)
+	if err != nil {
+		return 0, err
+	}
+	if b < 0 {
+		b += n
+	}
+	if b < 0 || b > n {
+		return 0, newError(KindEval, pos, "slice bound %v out of range with length %d", bv, n)
+	}
+	return b, nil
+}
+
+// sliceValue returns a copy of the elements or characters of v from low up to but not including high.
+func sliceValue(state *evalState, v any, low, high int, pos Pos) (any, error) {
+	if low > high {
+		return nil, newError(KindEval, pos, "invalid slice indices: %d > %d", low, high)
+	}
+	switch v := v.(type) {
+	case []any:
+		if err := state.allocate(high - low); err != nil {
+			return nil, err
+		}
+		return append([]any{}, v[low:high]...), nil
+	default:
+		return string([]rune(v.(string))[low:high]), nil
+	}
+}
+
+// index returns the integer index idx into a sequence of length n.
+// Negative indices count from the end of the sequence.
+func index(idx any, n int, pos Pos) (int, error) {
+	i, err := integer(idx, pos)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i >= n {
+		return 0, newError(KindEval, pos, "index %v out of range with length %d", idx, n)
+	}
+	return i, nil
+}
+
+// integer returns the index or slice bound v as an int.
+func integer(v any, pos Pos) (int, error) {
+	f, ok := v.(float64)
+	if !ok {
+		return 0, newError(KindType, pos, "index must be number: found %s", TypeName(v))
+	}
+	if f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+		return 0, newError(KindType, pos, "index must be integer: found %v", f)
+	}
+	return int(f), nil
+}
@@ -1,6 +1,8 @@
 package jsondsl
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
 
@@ -106,3 +108,185 @@ func TestEvalOpMapKey(t *testing.T) {
 		t.Errorf("TestEval(): got diff:\n%s", diff)
 	}
 }
+
+func TestEvalLambdaClosure(t *testing.T) {
+	src := `bind(const, lambda(x, lambda(y, x)))
+	bind(one, const(1))
+	bind(x, 2)
+	[one(3), const(4)(5), lambda(y, lambda(x, y))(6)(x)]`
+
+	got, err := EvalSource(BuiltinScope(), src)
+
+	wantErr := false
+	want := []any{float64(1), float64(4), float64(6)}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEval(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEval(): got diff:\n%s", diff)
+	}
+}
+
+func TestEvalBindStrictForm(t *testing.T) {
+	scope := BuiltinScope()
+	scope.BindStrict("strict", func(scope *Scope, args []any) (any, error) {
+		return args, nil
+	})
+	scope.BindForm("quote", func(scope *Scope, args []any) (any, error) {
+		return TypeName(args[0]), nil
+	})
+	src := `bind(x, 1)
+	[strict(x, add(x, 1)), quote(x), quote(undefined(1)), map([1, 2], strict)]`
+
+	got, err := EvalSource(scope, src)
+
+	wantErr := false
+	want := []any{
+		[]any{float64(1), float64(2)},
+		"op",
+		"op",
+		[]any{[]any{float64(1)}, []any{float64(2)}},
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalBindStrictForm(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalBindStrictForm(): got diff:\n%s", diff)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "tenant"))
+	defer cancel()
+
+	scope := BuiltinScope()
+	scope.BindContext("tenant", func(ctx context.Context, scope *Scope, args []any) (any, error) {
+		return ctx.Value(key{}), nil
+	})
+	scope.BindContext("cancel", func(ctx context.Context, scope *Scope, args []any) (any, error) {
+		cancel()
+		return nil, ctx.Err()
+	})
+	d := &Decoder{}
+	d.Reset(strings.NewReader(`[tenant(), map([1], lambda(x, tenant()))]`))
+	val, err := d.Decode()
+	if err != nil {
+		t.Fatalf("TestEvalContext(): failed to decode: %v", err)
+	}
+
+	got, err := EvalContext(ctx, scope, val)
+
+	want := []any{"tenant", []any{"tenant"}}
+	if err != nil {
+		t.Fatalf("TestEvalContext(): got err = %v, want nil", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalContext(): got diff:\n%s", diff)
+	}
+
+	if _, err := Eval(scope, &Op{Id: "tenant", Args: [][]any{{}}}); err != nil {
+		t.Errorf("TestEvalContext(): got err = %v without context, want nil", err)
+	}
+	if _, err := EvalContext(ctx, scope, &Op{Id: "cancel", Args: [][]any{{}}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("TestEvalContext(): got err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestEvalSelector(t *testing.T) {
+	src := `bind(config, {"db": {"host": "localhost", "port": 5432}, "double": lambda(x, mul(x, 2))})
+	[config.db.host, config.db.port, config.double(4), {"a": [1]}.a, get(config, "db").host]`
+
+	got, err := EvalSource(BuiltinScope(), src)
+
+	wantErr := false
+	want := []any{"localhost", float64(5432), float64(8), []any{float64(1)}, "localhost"}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalSelector(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalSelector(): got diff:\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		src    string
+		kind   ErrorKind
+		msg    string
+		offset int
+	}{
+		{`{"a": 1}.b`, KindName, `member "b" not found`, 9},
+		{`[1].a`, KindType, `cannot select "a" from array`, 4},
+	} {
+		_, err := EvalSource(BuiltinScope(), tc.src)
+		var got *Error
+		if !errors.As(err, &got) {
+			t.Errorf("TestEvalSelector(%q): got err = %v, want *Error", tc.src, err)
+			continue
+		}
+		if got.Kind != tc.kind || got.Msg != tc.msg || got.Position.Offset != tc.offset {
+			t.Errorf("TestEvalSelector(%q): got err = %q (kind %v, offset %d), want %q (kind %v, offset %d)", tc.src, got.Msg, got.Kind, got.Position.Offset, tc.msg, tc.kind, tc.offset)
+		}
+	}
+}
+
+func TestEvalIndex(t *testing.T) {
+	src := `bind(xs, [1, 2, 3, 4])
+	bind(o, {"a": {"b": [5, 6]}, 1: "one"})
+	[xs[0], xs[-1], xs[1:3], xs[:-2], xs[2:], xs[:], "héllo"[1], "héllo"[1:3], o["a"]["b"][1], o[1], o.a.b[-2]]`
+
+	got, err := EvalSource(BuiltinScope(), src)
+
+	wantErr := false
+	want := []any{
+		float64(1),
+		float64(4),
+		[]any{float64(2), float64(3)},
+		[]any{float64(1), float64(2)},
+		[]any{float64(3), float64(4)},
+		[]any{float64(1), float64(2), float64(3), float64(4)},
+		"é",
+		"él",
+		float64(6),
+		"one",
+		float64(5),
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalIndex(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestEvalIndex(): got diff:\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		src    string
+		kind   ErrorKind
+		msg    string
+		offset int
+	}{
+		{`[1, 2][2]`, KindEval, "index 2 out of range with length 2", 6},
+		{`[1, 2][-3]`, KindEval, "index -3 out of range with length 2", 6},
+		{`[1, 2][0.5]`, KindType, "index must be integer: found 0.5", 6},
+		{`[1, 2][3:]`, KindEval, "slice bound 3 out of range with length 2", 6},
+		{`[1, 2][2:1]`, KindEval, "invalid slice indices: 2 > 1", 6},
+		{`{"a": 1}["b"]`, KindName, `key "b" not found`, 8},
+		{`true[0]`, KindType, "cannot index bool", 4},
+	} {
+		_, err := EvalSource(BuiltinScope(), tc.src)
+		var got *Error
+		if !errors.As(err, &got) {
+			t.Errorf("TestEvalIndex(%q): got err = %v, want *Error", tc.src, err)
+			continue
+		}
+		if got.Kind != tc.kind || got.Msg != tc.msg || got.Position.Offset != tc.offset {
+			t.Errorf("TestEvalIndex(%q): got err = %q (kind %v, offset %d), want %q (kind %v, offset %d)", tc.src, got.Msg, got.Kind, got.Position.Offset, tc.msg, tc.kind, tc.offset)
+		}
+	}
+}
@@ -11,15 +11,24 @@ hex = digit | "A" … "F" | "a" … "f".
 escape = "\"" | "\\" | "/" | "b" | "f" | "n" | "r" | "t" | "u" hex hex hex hex.
 character = "\\" escape | "\x20" … "\x21" | "\x23" … "[" | "]" … "\uFFFF".
 string = "\"" {character} "\"".
-ws = {"\x20" | "\x0A" | "\x0D" | "\x09"}.
+newline = "\x0A".
+linecomment = "//" {"\x00" … "\x09" | "\x0B" … "\uFFFF"} (newline | EOF).
+blockcomment = "/*" {"\x00" … "\uFFFF"} "*/".
+comment = linecomment | blockcomment.
+ws = {"\x20" | newline | "\x0D" | "\x09" | comment}.
 idchar = "a" … "z" | "A" … "Z" | "_".
 ident = idchar {idchar | digit}.
-operator = ident ws "(" (ws | elements) ")".
+operator = ident {ws "(" (ws | elements) ")"}.
+selector = value ws "." ws operator.
+// An index or slice must immediately follow its value. Whitespace or a comment
+// before "[" ends the value, so that "x [1]" is the ident x followed by an array.
+index = value "[" element "]".
+slice = value "[" [element] ":" [element] "]".
 elements = element ["," [elements]].
 element = ws value ws.
 object = "{" (ws | members) "}".
 members = member ["," [members]].
 member = ws string ws ":" element.
 array = "[" (ws | elements) "]".
-value = null | bool | number | string | ident | operator | array | object.
+value = null | bool | number | string | ident | operator | selector | index | slice | array | object.
 stmt = null | bool | number | element
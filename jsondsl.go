@@ -1,5 +1,10 @@
 package jsondsl
 
+import (
+	"strings"
+	"unicode"
+)
+
 type Pos int
 
 const NoPos Pos = -1
@@ -14,6 +19,9 @@ type Value interface {
 }
 
 type (
+	BadValue struct {
+		From, To Pos // Range of the tokens which failed to parse.
+	}
 	Null struct{ NullPos Pos }
 	Bool struct {
 		LitPos  Pos
@@ -30,12 +38,14 @@ type (
 	Array struct {
 		LBrack   Pos
 		Elements []ListElem[Value]
+		Dangling *CommentGroup // Comments before the RBrack not preceding any element, if any.
 		RBrack   Pos
 	}
 	Object struct {
-		LBrace  Pos
-		Members []ListElem[*Member]
-		RBrace  Pos
+		LBrace   Pos
+		Members  []ListElem[*Member]
+		Dangling *CommentGroup // Comments before the RBrace not preceding any member, if any.
+		RBrace   Pos
 	}
 	Member struct {
 		Key   Value // Key excluding *Array and *Object.
@@ -50,17 +60,52 @@ type (
 		Id   *Ident
 		Args []*OperatorArgs
 	}
+	Selector struct {
+		X   Value     // Value selected from.
+		Dot Pos       // Position of the '.'.
+		Sel *Operator // Member name with optional call arguments.
+	}
+	Index struct {
+		X      Value // Value indexed.
+		LBrack Pos
+		Index  Value
+		RBrack Pos
+	}
+	Slice struct {
+		X      Value // Value sliced.
+		LBrack Pos
+		Low    Value // Begin of the slice or nil.
+		Colon  Pos
+		High   Value // End of the slice or nil.
+		RBrack Pos
+	}
 	OperatorArgs struct {
 		LParen    Pos
 		ValueList []ListElem[Value]
+		Dangling  *CommentGroup // Comments before the RParen not preceding any argument, if any.
 		RParen    Pos
 	}
 	ListElem[E Node] struct {
-		Value E
-		Comma Pos // CommaPos denotes the trailing comma, if any.
+		Doc     *CommentGroup // Doc denotes the comments preceding the element, if any.
+		Value   E
+		Comma   Pos           // CommaPos denotes the trailing comma, if any.
+		Comment *CommentGroup // Comment denotes the comments following the element on the same line, if any.
+	}
+	Comment struct {
+		Slash Pos    // Position of the leading '/'.
+		Text  string // Text including the comment markers.
+	}
+	CommentGroup struct {
+		List []*Comment // List is never empty.
 	}
 )
 
+func (a *BadValue) Pos() Pos {
+	if a == nil {
+		return NoPos
+	}
+	return a.From
+}
 func (a *Null) Pos() Pos {
 	if a == nil {
 		return NoPos
@@ -115,7 +160,84 @@ func (a *Operator) Pos() Pos {
 	}
 	return a.Id.Pos()
 }
+func (a *Selector) Pos() Pos {
+	if a == nil {
+		return NoPos
+	}
+	return a.X.Pos()
+}
+func (a *Index) Pos() Pos {
+	if a == nil {
+		return NoPos
+	}
+	return a.X.Pos()
+}
+func (a *Slice) Pos() Pos {
+	if a == nil {
+		return NoPos
+	}
+	return a.X.Pos()
+}
+func (a *OperatorArgs) Pos() Pos {
+	if a == nil {
+		return NoPos
+	}
+	return a.LParen
+}
+func (a *ListElem[E]) Pos() Pos {
+	if a == nil {
+		return NoPos
+	}
+	if a.Doc != nil {
+		return a.Doc.Pos()
+	}
+	return a.Value.Pos()
+}
+func (a *Comment) Pos() Pos {
+	if a == nil {
+		return NoPos
+	}
+	return a.Slash
+}
+func (a *CommentGroup) Pos() Pos {
+	if a == nil || len(a.List) == 0 {
+		return NoPos
+	}
+	return a.List[0].Pos()
+}
+
+// Text returns the text of the comment group with comment markers,
+// leading and trailing blank lines, and trailing whitespace removed.
+func (a *CommentGroup) Text() string {
+	if a == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range a.List {
+		text := c.Text
+		switch {
+		case strings.HasPrefix(text, "//"):
+			text = strings.TrimPrefix(text[2:], " ")
+		case strings.HasPrefix(text, "/*"):
+			text = strings.TrimSuffix(text[2:], "*/")
+		}
+		for _, l := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimRightFunc(l, unicode.IsSpace))
+		}
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
 
+func (*BadValue) val() {}
 func (*Null) val()     {}
 func (*Bool) val()     {}
 func (*Number) val()   {}
@@ -123,3 +245,6 @@ func (*String) val()   {}
 func (*Array) val()    {}
 func (*Object) val()   {}
 func (*Operator) val() {}
+func (*Selector) val() {}
+func (*Index) val()    {}
+func (*Slice) val()    {}
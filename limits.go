@@ -0,0 +1,126 @@
+package jsondsl
+
+import (
+	"context"
+	"errors"
+)
+
+// EvalOptions limit the resources used by an Evaluator.
+// Limits apply over the lifetime of the Evaluator and zero values mean no limit.
+type EvalOptions struct {
+	// MaxSteps limits the number of values evaluated.
+	MaxSteps int
+	// MaxDepth limits the depth of nested op calls, including recursive lambda calls.
+	MaxDepth int
+	// MaxAlloc limits the total number of array elements, object members, and string
+	// bytes produced by evaluation, counting array and object literals and the arrays,
+	// objects, and strings returned by ops.
+	MaxAlloc int
+	// Context aborts evaluation when it is done.
+	Context context.Context
+}
+
+// Errors wrapped by an *Error of KindLimit when a limit is exceeded.
+// Evaluation aborted by a Context wraps the error returned by its Err method.
+var (
+	ErrStepLimit  = errors.New("step limit exceeded")
+	ErrDepthLimit = errors.New("depth limit exceeded")
+	ErrAllocLimit = errors.New("allocation limit exceeded")
+)
+
+// ctxCheckInterval is the number of steps between checks of the Context.
+const ctxCheckInterval = 64
+
+// evalState tracks resources used during evaluation.
+// A nil *evalState imposes no limits.
+type evalState struct {
+	opts  EvalOptions
+	steps int
+	depth int
+	alloc int
+}
+
+func newEvalState(opts *EvalOptions) *evalState {
+	return &evalState{opts: *opts}
+}
+
+func limitError(err error) *Error {
+	return &Error{Kind: KindLimit, Pos: NoPos, Msg: err.Error(), Err: err}
+}
+
+// step counts an evaluation step, checking the Context periodically.
+func (s *evalState) step() error {
+	if s == nil {
+		return nil
+	}
+	s.steps++
+	if s.opts.MaxSteps > 0 && s.steps > s.opts.MaxSteps {
+		return limitError(ErrStepLimit)
+	}
+	if s.opts.Context != nil && s.steps%ctxCheckInterval == 1 {
+		if err := s.opts.Context.Err(); err != nil {
+			return limitError(err)
+		}
+	}
+	return nil
+}
+
+// enter counts a nested op call. Calls to enter must be paired with calls to exit.
+func (s *evalState) enter() error {
+	if s == nil {
+		return nil
+	}
+	s.depth++
+	if s.opts.MaxDepth > 0 && s.depth > s.opts.MaxDepth {
+		return limitError(ErrDepthLimit)
+	}
+	return nil
+}
+
+func (s *evalState) exit() {
+	if s != nil {
+		s.depth--
+	}
+}
+
+// allocate counts n array elements or object members.
+func (s *evalState) allocate(n int) error {
+	if s == nil {
+		return nil
+	}
+	s.alloc += n
+	if s.opts.MaxAlloc > 0 && s.alloc > s.opts.MaxAlloc {
+		return limitError(ErrAllocLimit)
+	}
+	return nil
+}
+
+// reserve returns an error if allocating n more elements would exceed the limit.
+// It allows ops to fail before building a large array or object.
+func (s *evalState) reserve(n float64) error {
+	if s != nil && s.opts.MaxAlloc > 0 && float64(s.alloc)+n > float64(s.opts.MaxAlloc) {
+		return limitError(ErrAllocLimit)
+	}
+	return nil
+}
+
+// allocateValue counts the elements, members, or bytes of v if it is an array, object, or string.
+func (s *evalState) allocateValue(v any) error {
+	switch v := v.(type) {
+	case string:
+		return s.allocate(len(v))
+	case []any:
+		return s.allocate(len(v))
+	case map[any]any:
+		return s.allocate(len(v))
+	}
+	return nil
+}
+
+// context returns the Context of the evaluation or the background context.
+func (s *evalState) context() context.Context {
+	if s == nil || s.opts.Context == nil {
+		return context.Background()
+	}
+	return s.opts.Context
+}
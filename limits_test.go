@@ -0,0 +1,182 @@
+package jsondsl
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEvalLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tc := range []struct {
+		name string
+		opts EvalOptions
+		src  string
+		want error
+	}{{
+		name: "steps",
+		opts: EvalOptions{MaxSteps: 100},
+		src:  `bind(f, lambda(x, f(x))) f(1)`,
+		want: ErrStepLimit,
+	}, {
+		name: "depth",
+		opts: EvalOptions{MaxDepth: 50},
+		src:  `bind(f, lambda(x, f(x))) f(1)`,
+		want: ErrDepthLimit,
+	}, {
+		name: "alloc",
+		opts: EvalOptions{MaxAlloc: 100},
+		src:  `range(1e12)`,
+		want: ErrAllocLimit,
+	}, {
+		name: "alloc cumulative",
+		opts: EvalOptions{MaxAlloc: 100},
+		src:  `map(range(10), lambda(x, range(10)))`,
+		want: ErrAllocLimit,
+	}, {
+		name: "context",
+		opts: EvalOptions{Context: canceled},
+		src:  `add(1, 2)`,
+		want: context.Canceled,
+	}, {
+		name: "context range",
+		opts: EvalOptions{Context: canceled},
+		src:  `range(1e9)`,
+		want: context.Canceled,
+	}, {
+		name: "within limits",
+		opts: EvalOptions{MaxSteps: 1000, MaxDepth: 20, MaxAlloc: 100, Context: context.Background()},
+		src:  `bind(f, lambda(x, if(le(x, 0), 0, add(x, f(sub(x, 1)))))) f(3)`,
+	}} {
+		_, err := NewEvaluator(BuiltinScope(), &tc.opts).EvalSource(tc.src)
+		if tc.want == nil {
+			if err != nil {
+				t.Errorf("TestEvalLimits(%s): got err = %v, want nil", tc.name, err)
+			}
+			continue
+		}
+		var got *Error
+		if !errors.As(err, &got) || got.Kind != KindLimit || !errors.Is(err, tc.want) {
+			t.Errorf("TestEvalLimits(%s): got err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestEvalLimitsLambdaCaller(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	prelude := BuiltinScope()
+	if _, err := NewEvaluator(prelude, &EvalOptions{MaxSteps: 200}).EvalSource(`bind(inc, lambda(x, add(x, 1)))`); err != nil {
+		t.Fatalf("TestEvalLimitsLambdaCaller(): got err = %v, want err = false", err)
+	}
+	if _, err := NewEvaluator(prelude, &EvalOptions{Context: ctx}).EvalSource(`bind(dec, lambda(x, sub(x, 1)))`); err != nil {
+		t.Fatalf("TestEvalLimitsLambdaCaller(): got err = %v, want err = false", err)
+	}
+	cancel()
+	prelude.Freeze()
+
+	got, err := EvalSource(prelude.LocalScope(), `reduce(range(100), lambda(a, x, dec(inc(inc(a)))), 0)`)
+
+	wantErr := false
+	want := float64(100)
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestEvalLimitsLambdaCaller(): got err = %v, want err = %v", err, wantErr)
+	}
+	if got != want {
+		t.Errorf("TestEvalLimitsLambdaCaller(): got %v, want %v", got, want)
+	}
+
+	_, err = NewEvaluator(prelude.LocalScope(), &EvalOptions{MaxSteps: 50}).EvalSource(`reduce(range(100), lambda(a, x, inc(a)), 0)`)
+	if !errors.Is(err, ErrStepLimit) {
+		t.Errorf("TestEvalLimitsLambdaCaller(): got err = %v, want %v", err, ErrStepLimit)
+	}
+}
+
+func TestEvalLimitsStrings(t *testing.T) {
+	_, err := NewEvaluator(BuiltinScope(), &EvalOptions{MaxAlloc: 1 << 20}).EvalSource(`reduce(range(60), lambda(acc, x, concat(acc, acc)), "x")`)
+	if !errors.Is(err, ErrAllocLimit) {
+		t.Errorf("TestEvalLimitsStrings(): got err = %v, want %v", err, ErrAllocLimit)
+	}
+}
+
+func TestEvalLimitsStringOps(t *testing.T) {
+	for name, src := range map[string]string{
+		"replace":    `replace(s, "", s)`,
+		"replaceAll": `replaceAll(s, "a", s)`,
+		"format":     `format("` + strings.Repeat("%[1]s", 5000) + `", s)`,
+	} {
+		scope := BuiltinScope()
+		scope.Bind("s", strings.Repeat("a", 5000))
+		e := NewEvaluator(scope, &EvalOptions{MaxAlloc: 20000})
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := e.EvalSource(src)
+		runtime.ReadMemStats(&after)
+
+		if !errors.Is(err, ErrAllocLimit) {
+			t.Errorf("TestEvalLimitsStringOps(%s): got err = %v, want %v", name, err, ErrAllocLimit)
+		}
+		// The full results are 25000000 bytes.
+		if n := after.TotalAlloc - before.TotalAlloc; n > 5000000 {
+			t.Errorf("TestEvalLimitsStringOps(%s): got %d bytes allocated, want the limit checked first", name, n)
+		}
+	}
+
+	for _, src := range []string{
+		`replace("abc", "b", "xyz")`,
+		`replaceAll("abc", "(b)", "[$1]")`,
+		`format("%[1]s-%[1]s", "ab")`,
+	} {
+		if _, err := NewEvaluator(BuiltinScope(), &EvalOptions{MaxAlloc: 20}).EvalSource(src); err != nil {
+			t.Errorf("TestEvalLimitsStringOps(%q): got err = %v, want err = false", src, err)
+		}
+	}
+}
+
+func TestDecodeNestingLimit(t *testing.T) {
+	src := strings.Repeat("[", maxNesting+1) + strings.Repeat("]", maxNesting+1)
+
+	_, err := EvalSource(BuiltinScope(), src)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestDecodeNestingLimit(): got err = %v, want *Error", err)
+	}
+	if want := "exceeded max nesting depth 10000"; got.Kind != KindSyntax || got.Msg != want {
+		t.Errorf("TestDecodeNestingLimit(): got err = %q (kind %v), want %q", got.Msg, got.Kind, want)
+	}
+	if len(got.Context) > maxContext+1 {
+		t.Errorf("TestDecodeNestingLimit(): got %d contexts, want at most %d", len(got.Context), maxContext+1)
+	}
+
+	src = strings.Repeat("[", maxNesting) + strings.Repeat("]", maxNesting)
+	if _, err := EvalSource(BuiltinScope(), src); err != nil {
+		t.Errorf("TestDecodeNestingLimit(): got err = %v at max depth, want err = false", err)
+	}
+}
+
+func TestEvalLimitsRange(t *testing.T) {
+	e := NewEvaluator(BuiltinScope(), &EvalOptions{MaxSteps: 1000, MaxAlloc: 1000})
+
+	got, err := e.EvalSource(`range(1e17, 100000000000000100)`)
+	if err != nil {
+		t.Fatalf("TestEvalLimitsRange(): got err = %v, want err = false", err)
+	}
+	// The end rounds to 1e17+96.
+	if n := len(got.([]any)); n != 96 {
+		t.Errorf("TestEvalLimitsRange(): got %d elements, want 96", n)
+	}
+
+	for _, src := range []string{`range(1e12)`, `range(0, 1, 0)`, `range(div(0, 0))`} {
+		if _, err := EvalSource(BuiltinScope(), src); err == nil {
+			t.Errorf("TestEvalLimitsRange(%q): got err = nil, want error", src)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package jsondsl
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// An Importer returns the source of the module at path.
+// Paths are slash-separated and cleaned as by path.Clean.
+type Importer interface {
+	Import(path string) ([]byte, error)
+}
+
+// FSImporter imports modules from a file system.
+type FSImporter struct {
+	FS fs.FS
+}
+
+func (i FSImporter) Import(path string) ([]byte, error) {
+	return fs.ReadFile(i.FS, path)
+}
+
+// MapImporter imports modules from a map of paths to sources.
+type MapImporter map[string]string
+
+func (i MapImporter) Import(path string) ([]byte, error) {
+	src, ok := i[path]
+	if !ok {
+		return nil, &fs.PathError{Op: "import", Path: path, Err: fs.ErrNotExist}
+	}
+	return []byte(src), nil
+}
+
+// A Loader loads modules using an Importer.
+//
+// Each module is evaluated once in its own local scope of the prelude and the result
+// is cached. The exports of a module are its bindings whose names do not begin with
+// an underscore, returned as an object so that they can be selected as in lib.name.
+// Import paths beginning with "./" or "../" are resolved relative to the directory
+// of the importing module.
+//
+// A Loader is not safe for concurrent use.
+type Loader struct {
+	importer Importer
+	prelude  *Scope
+	fset     *FileSet
+	modules  map[string]map[any]any
+	loading  []string // Stack of modules being loaded.
+}
+
+// NewLoader returns a Loader which imports modules using imp and evaluates them
+// in local scopes of prelude. A nil prelude uses the BuiltinScope.
+func NewLoader(imp Importer, prelude *Scope) *Loader {
+	if prelude == nil {
+		prelude = BuiltinScope()
+	}
+	return &Loader{
+		importer: imp,
+		prelude:  prelude,
+		fset:     NewFileSet(),
+		modules:  make(map[string]map[any]any),
+	}
+}
+
+// FileSet returns the file set of the modules loaded by l.
+func (l *Loader) FileSet() *FileSet {
+	return l.fset
+}
+
+// Bind binds the "import" op in scope.
+// The op takes the path of a module and returns its exports.
+// Errors of evaluations in scope report positions in modules using the FileSet of l.
+func (l *Loader) Bind(scope *Scope) {
+	scope.BindStrict("import", l.importOp("."))
+	scope.files = l.fset
+}
+
+// Import returns the exports of the module at path, loading it if needed.
+// Evaluation limits and the Context of the evaluation in scope apply while loading.
+func (l *Loader) Import(scope *Scope, path string) (map[any]any, error) {
+	return l.load(scope, resolvePath(".", path))
+}
+
+// importOp returns the "import" op for a module in the directory dir.
+func (l *Loader) importOp(dir string) StrictFunc {
+	return func(scope *Scope, args []any) (any, error) {
+		if err := checkArity("import", args, 1); err != nil {
+			return nil, err
+		}
+		p, err := stringArg("import", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return l.load(scope, resolvePath(dir, p))
+	}
+}
+
+// resolvePath resolves the import path p relative to dir if p is relative.
+func resolvePath(dir, p string) string {
+	if strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") {
+		p = path.Join(dir, p)
+	}
+	return path.Clean(p)
+}
+
+func (l *Loader) load(scope *Scope, p string) (map[any]any, error) {
+	if exports, ok := l.modules[p]; ok {
+		return exports, nil
+	}
+	for i, m := range l.loading {
+		if m == p {
+			cycle := append(append([]string{}, l.loading[i:]...), p)
+			return nil, newError(KindEval, NoPos, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	src, err := l.importer.Import(p)
+	if err != nil {
+		return nil, &Error{Kind: KindEval, Pos: NoPos, Msg: fmt.Sprintf("import %q: %v", p, err), Err: err}
+	}
+
+	l.loading = append(l.loading, p)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	// Bind import in a parent of the module scope so that it is not exported.
+	imports := l.prelude.LocalScope()
+	imports.BindStrict("import", l.importOp(path.Dir(p)))
+	module := imports.LocalScope()
+	state := scope.evalState()
+	e := &Evaluator{scope: module.withState(state), state: state}
+	if _, err := e.EvalFile(l.fset, p, string(src)); err != nil {
+		return nil, wrapError(err, fmt.Sprintf("in import %q", p))
+	}
+
+	names := make([]string, 0, len(module.Vars))
+	for name := range module.Vars {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	exports := make(map[any]any, len(names))
+	for _, name := range names {
+		exports[name] = module.Vars[name]
+	}
+	l.modules[p] = exports
+	return exports, nil
+}
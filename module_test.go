@@ -0,0 +1,219 @@
+package jsondsl
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestImport(t *testing.T) {
+	imp := MapImporter{
+		"lib/math.jsondsl": `bind(double, lambda(x, mul(x, 2)))
+bind(_factor, 10)
+bind(scale, lambda(x, mul(x, _factor)))
+bind(util, import("./util.jsondsl"))`,
+		"lib/util.jsondsl": `bind(name, "util")
+bind(loads, add(get({}, "x", 0), 1))`,
+	}
+	scope := BuiltinScope()
+	NewLoader(imp, nil).Bind(scope)
+
+	src := `bind(m, import("lib/math.jsondsl"))
+[m.double(2), m.scale(3), m.util.name, has(m, "_factor"), keys(m), import("lib/util.jsondsl").loads]`
+
+	got, err := EvalSource(scope, src)
+
+	wantErr := false
+	want := []any{float64(4), float64(30), "util", false, []any{"double", "scale", "util"}, float64(1)}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestImport(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestImport(): got diff:\n%s", diff)
+	}
+}
+
+func TestImportCache(t *testing.T) {
+	imp := MapImporter{"counter.jsondsl": `bind(n, 1)`}
+	l := NewLoader(imp, nil)
+	scope := BuiltinScope()
+
+	a, err := l.Import(scope, "counter.jsondsl")
+	if err != nil {
+		t.Fatalf("TestImportCache(): got err = %v, want err = false", err)
+	}
+	delete(imp, "counter.jsondsl")
+	b, err := l.Import(scope, "./counter.jsondsl")
+	if err != nil {
+		t.Fatalf("TestImportCache(): got err = %v, want err = false", err)
+	}
+	a["n"] = float64(2)
+	if got := b["n"]; got != float64(2) {
+		t.Errorf("TestImportCache(): got n = %v from second import, want the cached module", got)
+	}
+}
+
+func TestImportFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/main.jsondsl": {Data: []byte(`bind(db, import("../shared/db.jsondsl").db)`)},
+		"shared/db.jsondsl":   {Data: []byte(`bind(db, {"host": "localhost"})`)},
+	}
+	scope := BuiltinScope()
+	NewLoader(FSImporter{fsys}, nil).Bind(scope)
+
+	got, err := EvalSource(scope, `import("config/main.jsondsl").db.host`)
+
+	wantErr := false
+	want := "localhost"
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestImportFS(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestImportFS(): got diff:\n%s", diff)
+	}
+}
+
+func TestImportError(t *testing.T) {
+	imp := MapImporter{
+		"a.jsondsl":   `bind(b, import("b.jsondsl"))`,
+		"b.jsondsl":   `bind(a, import("a.jsondsl"))`,
+		"bad.jsondsl": `bind(x, add(1, "2"))`,
+	}
+
+	for _, tc := range []struct {
+		src  string
+		kind ErrorKind
+		msg  string
+	}{
+		{`import("a.jsondsl")`, KindEval, "import cycle: a.jsondsl -> b.jsondsl -> a.jsondsl"},
+		{`import("missing.jsondsl")`, KindEval, `import "missing.jsondsl": import missing.jsondsl: file does not exist`},
+		{`import(1)`, KindType, "import expects string in argument 0: found number"},
+		{`import("bad.jsondsl")`, KindType, "add expects number in argument 1: found string"},
+	} {
+		scope := BuiltinScope()
+		NewLoader(imp, nil).Bind(scope)
+		_, err := EvalSource(scope, tc.src)
+		var got *Error
+		if !errors.As(err, &got) {
+			t.Errorf("TestImportError(%q): got err = %v, want *Error", tc.src, err)
+			continue
+		}
+		if got.Kind != tc.kind || got.Msg != tc.msg {
+			t.Errorf("TestImportError(%q): got err = %q (kind %v), want %q (kind %v)", tc.src, got.Msg, got.Kind, tc.msg, tc.kind)
+		}
+	}
+
+	scope := BuiltinScope()
+	NewLoader(imp, nil).Bind(scope)
+	_, err := EvalSource(scope, `import("missing.jsondsl")`)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("TestImportError(): got err = %v, want fs.ErrNotExist", err)
+	}
+	_, err = EvalSource(scope, `import("bad.jsondsl")`)
+	if got, want := err.Error(), "bad.jsondsl:1:9:"; !strings.HasPrefix(got, want) {
+		t.Errorf("TestImportError(): got error string %q, want prefix %q", got, want)
+	}
+}
+
+func TestImportErrorPosition(t *testing.T) {
+	imp := MapImporter{"lib.jsondsl": `bind(g, lambda(x, x))
+bind(f, lambda(x,
+  div(g(x), "a")))`}
+	l := NewLoader(imp, nil)
+	scope := BuiltinScope()
+	l.Bind(scope)
+
+	_, err := NewEvaluator(scope, nil).EvalFile(l.FileSet(), "main.jsondsl", `bind(lib, import("lib.jsondsl"))
+lib.f(1)`)
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestImportErrorPosition(): got err = %v, want *Error", err)
+	}
+	want := Position{Filename: "lib.jsondsl", Offset: 42, Line: 3, Column: 3}
+	if diff := cmp.Diff(want, got.Position); diff != "" {
+		t.Errorf("TestImportErrorPosition(): got Position diff:\n%s", diff)
+	}
+	var frames []string
+	for _, f := range got.Stack {
+		frames = append(frames, f.Name+" "+f.Position.String())
+	}
+	wantFrames := []string{"div lib.jsondsl:3:3", "f main.jsondsl:2:5"}
+	if diff := cmp.Diff(wantFrames, frames); diff != "" {
+		t.Errorf("TestImportErrorPosition(): got frames diff:\n%s", diff)
+	}
+}
+
+func TestImportLimits(t *testing.T) {
+	imp := MapImporter{"lib.jsondsl": `bind(inc, lambda(x, add(x, 1)))`}
+	l := NewLoader(imp, nil)
+	scope := BuiltinScope()
+	l.Bind(scope)
+
+	if _, err := NewEvaluator(scope, &EvalOptions{MaxSteps: 100}).EvalSource(`import("lib.jsondsl")`); err != nil {
+		t.Fatalf("TestImportLimits(): got err = %v, want err = false", err)
+	}
+	got, err := EvalSource(scope, `reduce(range(100), lambda(a, x, import("lib.jsondsl").inc(a)), 0)`)
+	if err != nil {
+		t.Fatalf("TestImportLimits(): got err = %v, want err = false", err)
+	}
+	if got != float64(100) {
+		t.Errorf("TestImportLimits(): got %v, want 100", got)
+	}
+}
+
+func TestImportErrorPositionEvalSource(t *testing.T) {
+	imp := MapImporter{"lib.jsondsl": `bind(g, lambda(x, x))
+bind(f, lambda(x,
+  div(g(x), "a")))`}
+	scope := BuiltinScope()
+	NewLoader(imp, nil).Bind(scope)
+
+	_, err := EvalSource(scope, "bind(lib, import(\"lib.jsondsl\"))\n\n\n\n\nlib.f(1)")
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestImportErrorPositionEvalSource(): got err = %v, want *Error", err)
+	}
+	if got, want := got.Position.String(), "lib.jsondsl:3:3"; got != want {
+		t.Errorf("TestImportErrorPositionEvalSource(): got Position %s, want %s", got, want)
+	}
+	var frames []string
+	for _, f := range got.Stack {
+		frames = append(frames, f.Name+" "+f.Position.String())
+	}
+	wantFrames := []string{"div lib.jsondsl:3:3", "f 6:5"}
+	if diff := cmp.Diff(wantFrames, frames); diff != "" {
+		t.Errorf("TestImportErrorPositionEvalSource(): got frames diff:\n%s", diff)
+	}
+}
+
+func TestEvalSourcePositionsDisjoint(t *testing.T) {
+	scope := BuiltinScope()
+	if _, err := EvalSource(scope, `bind(f, lambda(x, div(x, "a")))`); err != nil {
+		t.Fatalf("TestEvalSourcePositionsDisjoint(): got err = %v, want err = false", err)
+	}
+
+	_, err := EvalSource(scope, "\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\n\nf(1)")
+
+	var got *Error
+	if !errors.As(err, &got) {
+		t.Fatalf("TestEvalSourcePositionsDisjoint(): got err = %v, want *Error", err)
+	}
+	// The div op is in a file which is no longer known, so its position must not
+	// be resolved against the file calling f.
+	if got.Position.IsValid() {
+		t.Errorf("TestEvalSourcePositionsDisjoint(): got Position %s, want unresolved", got.Position)
+	}
+	if len(got.Stack) != 2 || got.Stack[1].Position.String() != "23:1" {
+		t.Errorf("TestEvalSourcePositionsDisjoint(): got stack %v, want f at 23:1", got.Stack)
+	}
+}
@@ -1,39 +1,211 @@
 package jsondsl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
 	"strings"
 
 	"github.com/wenooij/bufiog"
 )
 
+// Mode controls optional parser behavior.
+type Mode uint
+
+const (
+	// AllErrors reports all errors rather than only the first.
+	// The parser resynchronizes after each error at the next ',' or closing delimiter
+	// and inserts BadValue nodes in place of values which could not be parsed.
+	AllErrors Mode = 1 << iota
+)
+
 type parser struct {
 	*bufiog.Reader[tokenPos]
+
+	file     *File
+	mode     Mode
+	errors   ErrorList
+	comments []comment // Pending comments in source order.
+}
+
+type comment struct {
+	tokenPos
+	prev Pos // Pos of the preceding non-comment token or NoPos.
+}
+
+// nextPos returns the Pos of the next token or NoPos at EOF.
+func (p *parser) nextPos() Pos {
+	es, err := p.Peek(1)
+	if err != nil || len(es) == 0 {
+		return NoPos
+	}
+	return es[0].Pos
+}
+
+// takeComments removes and returns the pending comments preceding the Pos before.
+// All pending comments are returned if before is NoPos.
+// If line is set, only comments on the same line as the preceding token are taken,
+// excluding block comments followed by the next token on the same line.
+func (p *parser) takeComments(before Pos, line bool) []comment {
+	i := 0
+	for ; i < len(p.comments); i++ {
+		c := p.comments[i]
+		if before != NoPos && c.Pos >= before {
+			break
+		}
+		if line && (c.prev == NoPos || p.file.Line(c.Pos) != p.file.Line(c.prev)) {
+			break
+		}
+		if line && before != NoPos && strings.HasPrefix(c.Text, "/*") &&
+			p.file.Line(c.Pos+Pos(len(c.Text))) == p.file.Line(before) {
+			break // Block comment preceding the next token on the same line.
+		}
+	}
+	cs := p.comments[:i:i]
+	p.comments = p.comments[i:]
+	return cs
 }
 
+// commentGroups splits comments into groups separated by blank lines.
+func (p *parser) commentGroups(cs []comment) []*CommentGroup {
+	var groups []*CommentGroup
+	var g *CommentGroup
+	endLine := 0
+	for _, c := range cs {
+		line := p.file.Line(c.Pos)
+		if g == nil || line > endLine+1 {
+			g = &CommentGroup{}
+			groups = append(groups, g)
+		}
+		g.List = append(g.List, &Comment{Slash: c.Pos, Text: c.Text})
+		endLine = p.file.Line(c.Pos + Pos(len(c.Text)))
+	}
+	return groups
+}
+
+// commentGroup returns all comments as a single group or nil.
+func commentGroup(cs []comment) *CommentGroup {
+	if len(cs) == 0 {
+		return nil
+	}
+	g := &CommentGroup{List: make([]*Comment, 0, len(cs))}
+	for _, c := range cs {
+		g.List = append(g.List, &Comment{Slash: c.Pos, Text: c.Text})
+	}
+	return g
+}
+
+// consumeToken consumes the next token if it is t.
 func (p *parser) consumeToken(t Token) (Pos, error) {
-	e, err := p.ReadElem()
+	es, err := p.Peek(1)
 	if err != nil {
+		if err == io.EOF {
+			return NoPos, io.ErrUnexpectedEOF
+		}
 		return NoPos, err
 	}
-	if e.Token != t {
-		return NoPos, fmt.Errorf("expected token %s (found %s)", t, e.Token)
+	if e := es[0]; e.Token != t {
+		return NoPos, syntaxError(e, "expected token %s (found %s)", t, e.Token)
 	}
-	return e.Pos, nil
+	p.Discard(1)
+	return es[0].Pos, nil
 }
 
-func Parse(src string) ([]Node, error) {
-	t := &Tokenizer{}
-	sc := bufio.NewScanner(strings.NewReader(src))
-	sc.Split(t.SplitFunc)
-	p := &parser{
-		Reader: bufiog.NewReaderSize(&tokenReader{
-			t:  t,
-			sc: sc,
-		}, 64),
+// consumeClose consumes the closing delimiter t of a list.
+// In AllErrors mode, a missing delimiter is recorded and NoPos is returned.
+func (p *parser) consumeClose(t Token, context string) (Pos, error) {
+	pos, err := p.consumeToken(t)
+	if err != nil {
+		if !p.recoverable(err) {
+			return NoPos, wrapError(err, context)
+		}
+		p.error(wrapError(err, context))
+	}
+	return pos, nil
+}
+
+// recoverable reports whether the parser should record err and resynchronize.
+// Only syntax errors are recoverable since the input ends after scan errors and EOF.
+func (p *parser) recoverable(err error) bool {
+	return p.mode&AllErrors != 0 && asError(err).Kind == KindSyntax
+}
+
+func (p *parser) error(err error) {
+	p.errors = append(p.errors, resolveError(err, p.file).(*Error))
+}
+
+// sync skips tokens until a ',' or closing delimiter outside of any nested list
+// and returns the Pos of that token. It returns NoPos at EOF.
+func (p *parser) sync() Pos {
+	depth := 0
+	for {
+		es, err := p.Peek(1)
+		if err != nil {
+			return NoPos
+		}
+		switch es[0].Token {
+		case TokenLBrace, TokenLBrack, TokenLParen:
+			depth++
+		case TokenRBrace, TokenRBrack, TokenRParen:
+			if depth == 0 {
+				return es[0].Pos
+			}
+			depth--
+		case TokenComma:
+			if depth == 0 {
+				return es[0].Pos
+			}
+		}
+		p.Discard(1)
+	}
+}
+
+// syncValue skips at least one token and any following tokens which
+// cannot start a value and returns the Pos of the next token.
+// It returns NoPos at EOF.
+func (p *parser) syncValue() Pos {
+	p.Discard(1)
+	for {
+		es, err := p.Peek(1)
+		if err != nil {
+			return NoPos
+		}
+		if startsValue(es[0].Token) {
+			return es[0].Pos
+		}
+		p.Discard(1)
+	}
+}
+
+func startsValue(t Token) bool {
+	switch t {
+	case TokenLBrace, TokenLBrack, TokenNull, TokenFalse, TokenTrue, TokenNumber, TokenIdent, TokenString:
+		return true
+	default:
+		return false
 	}
+}
+
+func isClose(t Token) bool {
+	return t == TokenRBrace || t == TokenRBrack || t == TokenRParen
+}
+
+// Parse parses all values in src.
+// Positions of the returned nodes are byte offsets into src.
+func Parse(src string) ([]Node, error) {
+	return ParseFile(NewFileSet(), "", src, 0)
+}
+
+// ParseFile parses all values in src and adds the source file to fset.
+// Positions of the returned nodes are relative to fset.
+//
+// Without AllErrors, parsing stops at the first error which is returned as an *Error.
+// With AllErrors, ParseFile returns the nodes parsed, including BadValue nodes, and an
+// ErrorList of all errors found.
+func ParseFile(fset *FileSet, filename string, src string, mode Mode) ([]Node, error) {
+	f := fset.AddFile(filename, -1, len(src))
+	p := &parser{file: f, mode: mode}
+	p.Reader = newTokenReader(f, strings.NewReader(src), func(c tokenPos, prev Pos) {
+		p.comments = append(p.comments, comment{tokenPos: c, prev: prev})
+	})
 
 	out := []Node{}
 	for {
@@ -41,21 +213,124 @@ func Parse(src string) ([]Node, error) {
 			if err == io.EOF {
 				break
 			}
-			return nil, err
+			if p.mode&AllErrors == 0 {
+				return nil, resolveError(err, f)
+			}
+			p.error(err)
+			break
+		}
+		for _, g := range p.commentGroups(p.takeComments(p.nextPos(), false)) {
+			out = append(out, g)
 		}
+		from := p.nextPos()
 		val, err := p.parseValue()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return nil, err
+			if p.mode&AllErrors == 0 {
+				return nil, resolveError(err, f)
+			}
+			p.error(err)
+			if !p.recoverable(err) {
+				break
+			}
+			val = &BadValue{From: from, To: p.syncValue()}
 		}
 		out = append(out, val)
+		if g := commentGroup(p.takeComments(p.nextPos(), true)); g != nil {
+			out = append(out, g)
+		}
+	}
+	for _, g := range p.commentGroups(p.takeComments(NoPos, false)) {
+		out = append(out, g)
+	}
+	if p.mode&AllErrors != 0 {
+		p.errors.Sort()
+		return out, p.errors.Err()
 	}
 	return out, nil
 }
 
 func (p *parser) parseValue() (Value, error) {
+	v, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return p.parsePostfix(v)
+}
+
+// parsePostfix parses any selectors, indices, and slices following the value x.
+// An index or slice must immediately follow x so that it is not confused with an array.
+func (p *parser) parsePostfix(x Value) (Value, error) {
+	for {
+		es, err := p.Peek(1)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		switch {
+		case len(es) == 0:
+			return x, nil
+		case es[0].Token == TokenDot:
+			p.Discard(1)
+			sel, err := p.parseOperator()
+			if err != nil {
+				return nil, wrapError(err, "in selector")
+			}
+			x = &Selector{X: x, Dot: es[0].Pos, Sel: sel}
+		case es[0].Token == TokenLBrack && !es[0].Space:
+			if x, err = p.parseIndex(x); err != nil {
+				return nil, err
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// parseIndex parses an index or slice of x.
+func (p *parser) parseIndex(x Value) (Value, error) {
+	lb, err := p.consumeToken(TokenLBrack)
+	if err != nil {
+		return nil, wrapError(err, "at start of index")
+	}
+	var low Value
+	if !p.peekToken(TokenColon) {
+		if low, err = p.parseValue(); err != nil {
+			return nil, wrapError(err, "in index")
+		}
+		if !p.peekToken(TokenColon) {
+			rb, err := p.consumeClose(TokenRBrack, "at end of index")
+			if err != nil {
+				return nil, err
+			}
+			return &Index{X: x, LBrack: lb, Index: low, RBrack: rb}, nil
+		}
+	}
+	colon, err := p.consumeToken(TokenColon)
+	if err != nil {
+		return nil, wrapError(err, "in slice")
+	}
+	var high Value
+	if !p.peekToken(TokenRBrack) {
+		if high, err = p.parseValue(); err != nil {
+			return nil, wrapError(err, "in slice")
+		}
+	}
+	rb, err := p.consumeClose(TokenRBrack, "at end of slice")
+	if err != nil {
+		return nil, err
+	}
+	return &Slice{X: x, LBrack: lb, Low: low, Colon: colon, High: high, RBrack: rb}, nil
+}
+
+// peekToken reports whether the next token is t.
+func (p *parser) peekToken(t Token) bool {
+	es, _ := p.Peek(1)
+	return len(es) > 0 && es[0].Token == t
+}
+
+func (p *parser) parseOperand() (Value, error) {
 	es, err := p.Peek(1)
 	if err != nil {
 		if err == io.EOF {
@@ -65,9 +340,9 @@ func (p *parser) parseValue() (Value, error) {
 	}
 	switch e := es[0]; e.Token {
 	case TokenInvalid:
-		return nil, fmt.Errorf("invalid token returned during scan")
-	case TokenColon, TokenComma, TokenLParen, TokenRParen, TokenRBrace, TokenRBrack:
-		return nil, fmt.Errorf("unexpected token %s at beginning of Value", e.Token)
+		return nil, syntaxError(e, "invalid token returned during scan")
+	case TokenColon, TokenComma, TokenDot, TokenLParen, TokenRParen, TokenRBrace, TokenRBrack:
+		return nil, syntaxError(e, "unexpected token %s at beginning of Value", e.Token)
 	case TokenLBrace:
 		object, err := p.parseObject()
 		if err != nil {
@@ -101,49 +376,52 @@ func (p *parser) parseValue() (Value, error) {
 	case TokenString:
 		return p.parseString()
 	default:
-		return nil, fmt.Errorf("unknown token %s returned during scan", e.Token)
+		return nil, syntaxError(e, "unknown token %s returned during scan", e.Token)
 	}
 }
 
 func (p *parser) parseArray() (*Array, error) {
 	lb, err := p.consumeToken(TokenLBrack)
 	if err != nil {
-		return nil, fmt.Errorf("%v at start of array", err)
+		return nil, wrapError(err, "at start of array")
 	}
-	elems, err := parseList(p, TokenRBrack, p.parseValue)
+	elems, dangling, err := parseList(p, TokenRBrack, p.parseValue, badValue)
 	if err != nil {
-		return nil, fmt.Errorf("%v in array", err)
+		return nil, wrapError(err, "in array")
 	}
-	rb, err := p.consumeToken(TokenRBrack)
+	rb, err := p.consumeClose(TokenRBrack, "at end of array")
 	if err != nil {
-		return nil, fmt.Errorf("%v at end of array", err)
+		return nil, err
 	}
-	return &Array{LBrack: lb, Elements: elems, RBrack: rb}, nil
+	return &Array{LBrack: lb, Elements: elems, Dangling: dangling, RBrack: rb}, nil
 }
 
 func (p *parser) parseObject() (*Object, error) {
 	lb, err := p.consumeToken(TokenLBrace)
 	if err != nil {
-		return nil, fmt.Errorf("%v at beginning of object", err)
+		return nil, wrapError(err, "at beginning of object")
 	}
-	members, err := parseList(p, TokenRBrace, p.parseMember)
+	members, dangling, err := parseList(p, TokenRBrace, p.parseMember, badMember)
 	if err != nil {
-		return nil, fmt.Errorf("%v in object", err)
+		return nil, wrapError(err, "in object")
 	}
-	rb, err := p.consumeToken(TokenRBrace)
+	rb, err := p.consumeClose(TokenRBrace, "at end of object")
 	if err != nil {
-		return nil, fmt.Errorf("%v at end of object", err)
+		return nil, err
 	}
-	return &Object{LBrace: lb, Members: members, RBrace: rb}, nil
+	return &Object{LBrace: lb, Members: members, Dangling: dangling, RBrace: rb}, nil
 }
 
 func (p *parser) parseString() (*String, error) {
 	e, err := p.ReadElem()
 	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
 	if e.Token != TokenString {
-		return nil, fmt.Errorf("expected token %s (found %s)", TokenString, e.Token)
+		return nil, syntaxError(e, "expected token %s (found %s)", TokenString, e.Token)
 	}
 	return &String{Quote: e.Pos, QuotedContent: e.Text}, nil
 }
@@ -151,10 +429,13 @@ func (p *parser) parseString() (*String, error) {
 func (p *parser) parseIdent() (*Ident, error) {
 	e, err := p.ReadElem()
 	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
 	if e.Token != TokenIdent {
-		return nil, fmt.Errorf("expected token %s (found %s)", TokenIdent, e.Token)
+		return nil, syntaxError(e, "expected token %s (found %s)", TokenIdent, e.Token)
 	}
 	return &Ident{NamePos: e.Pos, Name: e.Text}, nil
 }
@@ -162,23 +443,23 @@ func (p *parser) parseIdent() (*Ident, error) {
 func (p *parser) parseMember() (*Member, error) {
 	key, err := p.parseValue()
 	if err != nil {
-		return nil, fmt.Errorf("%v at member key", err)
+		return nil, wrapError(err, "at member key")
 	}
 	colon, err := p.consumeToken(TokenColon)
 	if err != nil {
-		return nil, fmt.Errorf("%v in object member", err)
+		return nil, wrapError(err, "in object member")
 	}
 	value, err := p.parseValue()
 	if err != nil {
-		return nil, fmt.Errorf("%v at member Value", err)
+		return nil, wrapError(err, "at member Value")
 	}
 	return &Member{Key: key, Colon: colon, Value: value}, nil
 }
 
-func (p *parser) parseOperator() (Value, error) {
+func (p *parser) parseOperator() (*Operator, error) {
 	id, err := p.parseIdent()
 	if err != nil {
-		return nil, fmt.Errorf("%v at start of operator", err)
+		return nil, wrapError(err, "at start of operator")
 	}
 	var opArgs []*OperatorArgs
 	for {
@@ -201,64 +482,98 @@ func (p *parser) parseOperator() (Value, error) {
 func (p *parser) parseOperatorArgs() (*OperatorArgs, error) {
 	lp, err := p.consumeToken(TokenLParen)
 	if err != nil {
-		return nil, fmt.Errorf("%v at start of operator arguments", err)
+		return nil, wrapError(err, "at start of operator arguments")
 	}
-	args, err := parseList(p, TokenRParen, p.parseValue)
+	args, dangling, err := parseList(p, TokenRParen, p.parseValue, badValue)
 	if err != nil {
-		return nil, fmt.Errorf("%v at operator arguments", err)
+		return nil, wrapError(err, "at operator arguments")
 	}
-	rp, err := p.consumeToken(TokenRParen)
+	rp, err := p.consumeClose(TokenRParen, "at end of operator")
 	if err != nil {
-		return nil, fmt.Errorf("%v at end of operator", err)
+		return nil, err
 	}
 	return &OperatorArgs{
 		LParen:    lp,
 		ValueList: args,
+		Dangling:  dangling,
 		RParen:    rp,
 	}, nil
 }
 
+func badValue(from, to Pos) Value { return &BadValue{From: from, To: to} }
+
+func badMember(from, to Pos) *Member {
+	return &Member{Key: &BadValue{From: from, To: to}, Colon: NoPos, Value: &BadValue{From: from, To: to}}
+}
+
 // parseList parses a generic list of Nodes as seen in the object, array, and operator specs.
 // It parses the contents of the list including TokenComma, but does not consume the provided
-// delim.
+// delim. Comments before delim which do not precede any element are returned as dangling.
+//
+// In AllErrors mode, elements which fail to parse are recorded and replaced with the result
+// of badFn, and the list ends early at any other closing delimiter, leaving the caller to
+// report the missing delim.
 //
 // precondition: delim is one of: TokenRBrack, TokenBrace, or TokenRParen.
-func parseList[E Node](p *parser, delim Token, parseFn func() (E, error)) ([]ListElem[E], error) {
-	out := []ListElem[E](nil)
-
+func parseList[E Node](p *parser, delim Token, parseFn func() (E, error), badFn func(from, to Pos) E) (out []ListElem[E], dangling *CommentGroup, err error) {
 	for done := false; !done; {
 		es, err := p.Peek(1)
 		if err != nil {
 			if err == io.EOF {
-				return nil, io.ErrUnexpectedEOF
+				return nil, nil, io.ErrUnexpectedEOF
 			}
-			return nil, err
+			return nil, nil, err
 		}
-		if es[0].Token == delim {
+		doc := commentGroup(p.takeComments(es[0].Pos, false))
+		if es[0].Token == delim || p.mode&AllErrors != 0 && isClose(es[0].Token) {
+			dangling = doc
 			break
 		}
+		from := es[0].Pos
 		v, err := parseFn()
 		if err != nil {
-			return nil, err
+			if !p.recoverable(err) {
+				return nil, nil, err
+			}
+			p.error(err)
+			v = badFn(from, p.sync())
 		}
 		es, err = p.Peek(1)
 		if err != nil {
 			if err == io.EOF {
-				return nil, io.ErrUnexpectedEOF
+				return nil, nil, io.ErrUnexpectedEOF
 			}
-			return nil, err
+			return nil, nil, err
 		}
 		var comma Pos
-		switch es[0].Token {
-		case TokenComma:
-			comma = es[0].Pos
+		switch e := es[0]; {
+		case e.Token == TokenComma:
+			comma = e.Pos
 			p.Discard(1)
-		case delim:
+		case e.Token == delim:
 			done = true
+		case p.mode&AllErrors == 0:
+			return nil, nil, syntaxError(e, "expected token %s (found %s)", TokenComma, e.Token)
+		case isClose(e.Token):
+			done = true
+		case startsValue(e.Token):
+			p.error(syntaxError(e, "expected token %s (found %s)", TokenComma, e.Token))
 		default:
-			return nil, fmt.Errorf("expected token %s (found %s)", TokenComma, es[0].Token)
+			p.error(syntaxError(e, "expected token %s (found %s)", TokenComma, e.Token))
+			if p.sync(); p.nextPos() != NoPos {
+				if es, _ := p.Peek(1); es[0].Token == TokenComma {
+					comma = es[0].Pos
+					p.Discard(1)
+				} else {
+					done = true
+				}
+			}
+		}
+		line := commentGroup(p.takeComments(p.nextPos(), true))
+		out = append(out, ListElem[E]{Doc: doc, Value: v, Comma: comma, Comment: line})
+		if done {
+			dangling = commentGroup(p.takeComments(p.nextPos(), false))
 		}
-		out = append(out, ListElem[E]{Value: v, Comma: comma})
 	}
-	return out, nil
+	return out, dangling, nil
 }
@@ -1,6 +1,7 @@
 package jsondsl
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -137,3 +138,188 @@ func TestParseEverythingArray(t *testing.T) {
 		t.Errorf("TestParse(): got diff:\n%s", diff)
 	}
 }
+
+func TestParseComments(t *testing.T) {
+	input := `// doc
+[ // first
+	1, // one
+	/* two */ 2
+	// end
+] // trailing`
+
+	got, err := Parse(input)
+
+	wantErr := false
+	want := []Node{
+		&CommentGroup{List: []*Comment{{Slash: 0, Text: "// doc"}}},
+		&Array{
+			LBrack: 7,
+			Elements: []ListElem[Value]{
+				{
+					Doc:     &CommentGroup{List: []*Comment{{Slash: 9, Text: "// first"}}},
+					Value:   &Number{LitPos: 19, Literal: "1"},
+					Comma:   20,
+					Comment: &CommentGroup{List: []*Comment{{Slash: 22, Text: "// one"}}},
+				},
+				{
+					Doc:   &CommentGroup{List: []*Comment{{Slash: 30, Text: "/* two */"}}},
+					Value: &Number{LitPos: 40, Literal: "2"},
+				},
+			},
+			Dangling: &CommentGroup{List: []*Comment{{Slash: 43, Text: "// end"}}},
+			RBrack:   50,
+		},
+		&CommentGroup{List: []*Comment{{Slash: 52, Text: "// trailing"}}},
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestParse(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestParse(): got diff:\n%s", diff)
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	input := `config.db.host f(x).g(1)`
+
+	got, err := Parse(input)
+
+	wantErr := false
+	want := []Node{
+		&Selector{
+			X: &Selector{
+				X:   &Operator{Id: &Ident{NamePos: 0, Name: "config"}},
+				Dot: 6,
+				Sel: &Operator{Id: &Ident{NamePos: 7, Name: "db"}},
+			},
+			Dot: 9,
+			Sel: &Operator{Id: &Ident{NamePos: 10, Name: "host"}},
+		},
+		&Selector{
+			X: &Operator{
+				Id: &Ident{NamePos: 15, Name: "f"},
+				Args: []*OperatorArgs{{
+					LParen:    16,
+					ValueList: []ListElem[Value]{{Value: &Operator{Id: &Ident{NamePos: 17, Name: "x"}}}},
+					RParen:    18,
+				}},
+			},
+			Dot: 19,
+			Sel: &Operator{
+				Id: &Ident{NamePos: 20, Name: "g"},
+				Args: []*OperatorArgs{{
+					LParen:    21,
+					ValueList: []ListElem[Value]{{Value: &Number{LitPos: 22, Literal: "1"}}},
+					RParen:    23,
+				}},
+			},
+		},
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestParseSelector(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestParseSelector(): got diff:\n%s", diff)
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	input := `x[0] x[:-1] x [1]`
+
+	got, err := Parse(input)
+
+	wantErr := false
+	want := []Node{
+		&Index{
+			X:      &Operator{Id: &Ident{NamePos: 0, Name: "x"}},
+			LBrack: 1,
+			Index:  &Number{LitPos: 2, Literal: "0"},
+			RBrack: 3,
+		},
+		&Slice{
+			X:      &Operator{Id: &Ident{NamePos: 5, Name: "x"}},
+			LBrack: 6,
+			Colon:  7,
+			High:   &Number{LitPos: 8, Literal: "-1"},
+			RBrack: 10,
+		},
+		&Operator{Id: &Ident{NamePos: 12, Name: "x"}},
+		&Array{
+			LBrack:   14,
+			Elements: []ListElem[Value]{{Value: &Number{LitPos: 15, Literal: "1"}}},
+			RBrack:   16,
+		},
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestParseIndex(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestParseIndex(): got diff:\n%s", diff)
+	}
+}
+
+func TestParseAllErrors(t *testing.T) {
+	input := `[1 2, :, {"a" 1}, x]
+op(]
+3`
+
+	got, err := ParseFile(NewFileSet(), "", input, AllErrors)
+
+	want := []Node{
+		&Array{
+			LBrack: 0,
+			Elements: []ListElem[Value]{
+				{Value: &Number{LitPos: 1, Literal: "1"}},
+				{Value: &Number{LitPos: 3, Literal: "2"}, Comma: 4},
+				{Value: &BadValue{From: 6, To: 7}, Comma: 7},
+				{Value: &Object{
+					LBrace: 9,
+					Members: []ListElem[*Member]{{
+						Value: &Member{
+							Key:   &BadValue{From: 10, To: 15},
+							Colon: NoPos,
+							Value: &BadValue{From: 10, To: 15},
+						},
+					}},
+					RBrace: 15,
+				}, Comma: 16},
+				{Value: &Operator{Id: &Ident{NamePos: 18, Name: "x"}}},
+			},
+			RBrack: 19,
+		},
+		&Operator{
+			Id:   &Ident{NamePos: 21, Name: "op"},
+			Args: []*OperatorArgs{{LParen: 23, RParen: NoPos}},
+		},
+		&BadValue{From: 24, To: 26},
+		&Number{LitPos: 26, Literal: "3"},
+	}
+	wantErrs := []string{
+		"1:4: expected token Comma (found Number)",
+		"1:7: unexpected token Colon at beginning of Value",
+		"1:15: expected token Colon (found Number) in object member",
+		"2:4: expected token RParen (found RBrack) at end of operator",
+		"2:4: unexpected token RBrack at beginning of Value",
+	}
+
+	var errs ErrorList
+	if !errors.As(err, &errs) {
+		t.Fatalf("TestParseAllErrors(): got err = %v, want ErrorList", err)
+	}
+	var gotErrs []string
+	for _, e := range errs {
+		gotErrs = append(gotErrs, e.Error())
+	}
+	if diff := cmp.Diff(wantErrs, gotErrs); diff != "" {
+		t.Errorf("TestParseAllErrors(): got errors diff:\n%s", diff)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestParseAllErrors(): got diff:\n%s", diff)
+	}
+}
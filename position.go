@@ -0,0 +1,198 @@
+package jsondsl
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Position describes a resolved source position including the file, line, and column.
+// A Position is valid if the line number is > 0.
+type Position struct {
+	Filename string // Filename, if any.
+	Offset   int    // Byte offset, starting at 0.
+	Line     int    // Line number, starting at 1.
+	Column   int    // Column number, starting at 1 (byte count).
+}
+
+// IsValid reports whether the position is valid.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns a string in one of several forms:
+//
+//	file:line:column    valid position with file name
+//	line:column         valid position without file name
+//	file                invalid position with file name
+//	-                   invalid position without file name
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// File is a handle for a source file belonging to a FileSet.
+// It records the offsets of line starts so that any Pos within
+// the file can be resolved to a Position.
+type File struct {
+	name string
+	base int
+	size int
+
+	mu    sync.Mutex
+	lines []int // Offsets of the first character of each line.
+}
+
+// Name returns the file name as registered with AddFile.
+func (f *File) Name() string { return f.name }
+
+// Base returns the base offset of f as registered with AddFile.
+func (f *File) Base() int { return f.base }
+
+// Size returns the size of f as registered with AddFile.
+func (f *File) Size() int { return f.size }
+
+// LineCount returns the number of lines recorded in f.
+func (f *File) LineCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.lines)
+}
+
+// AddLine records the offset of a new line start.
+// The offset must be larger than the offset of the previous line, otherwise it is ignored.
+func (f *File) AddLine(offset int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i := len(f.lines); i == 0 || f.lines[i-1] < offset {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// Pos returns the Pos value for the given file offset.
+func (f *File) Pos(offset int) Pos {
+	return Pos(f.base + offset)
+}
+
+// Offset returns the offset in f for the given Pos.
+func (f *File) Offset(p Pos) int {
+	return int(p) - f.base
+}
+
+// Line returns the line number for the given Pos.
+func (f *File) Line(p Pos) int {
+	return f.Position(p).Line
+}
+
+// Position returns the resolved Position for the given Pos.
+// It returns the zero Position for NoPos.
+func (f *File) Position(p Pos) Position {
+	if p == NoPos {
+		return Position{}
+	}
+	offset := f.Offset(p)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	if i < 0 {
+		return Position{Filename: f.name, Offset: offset}
+	}
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     i + 1,
+		Column:   offset - f.lines[i] + 1,
+	}
+}
+
+// FileSet represents a set of source files.
+// Positions of files in the set are disjoint, such that any Pos
+// maps back to a single File.
+type FileSet struct {
+	mu    sync.RWMutex
+	base  int
+	files []*File
+}
+
+// NewFileSet creates a new file set.
+func NewFileSet() *FileSet {
+	return &FileSet{}
+}
+
+// Base returns the minimum base offset that must be provided to AddFile
+// when adding the next file.
+func (s *FileSet) Base() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.base
+}
+
+// AddFile adds a new file with the given filename, base offset, and file size to the set.
+// If base is negative, the current Base is used instead.
+// The first file added to a new FileSet has base 0 so that its Pos values are plain offsets.
+func (s *FileSet) AddFile(filename string, base, size int) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if base < 0 {
+		base = s.base
+	}
+	if base < s.base {
+		panic(fmt.Sprintf("invalid base %d (should be >= %d)", base, s.base))
+	}
+	if size < 0 {
+		panic(fmt.Sprintf("invalid size %d (should be >= 0)", size))
+	}
+	f := &File{name: filename, base: base, size: size, lines: []int{0}}
+	s.base = base + size + 1 // +1 because EOF also has a position.
+	s.files = append(s.files, f)
+	return f
+}
+
+// evalBase is the base of the next range of positions reserved by reserveBase.
+var evalBase atomic.Int64
+
+// reserveBase reserves a range of positions for a file of the given size with a base
+// no less than min and returns the base. Reserved ranges never overlap, so that the
+// positions of ops evaluated from different files, even of different file sets, differ.
+func reserveBase(min, size int) int {
+	for {
+		old := evalBase.Load()
+		base := max(old, int64(min))
+		if evalBase.CompareAndSwap(old, base+int64(size)+1) {
+			return int(base)
+		}
+	}
+}
+
+// File returns the file that contains the position p or nil if none exists.
+func (s *FileSet) File(p Pos) *File {
+	if p == NoPos {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i < 0 {
+		return nil
+	}
+	if f := s.files[i]; int(p) <= f.base+f.size {
+		return f
+	}
+	return nil
+}
+
+// Position converts a Pos in the file set into a Position.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
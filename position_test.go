@@ -0,0 +1,46 @@
+package jsondsl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileSetPosition(t *testing.T) {
+	fset := NewFileSet()
+	a, err := ParseFile(fset, "a.jsondsl", "[\n\t1,\n\t2\n]", 0)
+	if err != nil {
+		t.Fatalf("TestFileSetPosition(): failed to parse a: %v", err)
+	}
+	b, err := ParseFile(fset, "b.jsondsl", "null\n  op(x)", 0)
+	if err != nil {
+		t.Fatalf("TestFileSetPosition(): failed to parse b: %v", err)
+	}
+
+	arr := a[0].(*Array)
+	got := []string{
+		fset.Position(arr.Pos()).String(),
+		fset.Position(arr.Elements[0].Value.Pos()).String(),
+		fset.Position(arr.Elements[1].Value.Pos()).String(),
+		fset.Position(arr.RBrack).String(),
+		fset.Position(b[0].Pos()).String(),
+		fset.Position(b[1].Pos()).String(),
+		fset.Position(b[1].(*Operator).Args[0].LParen).String(),
+		fset.Position(NoPos).String(),
+	}
+
+	want := []string{
+		"a.jsondsl:1:1",
+		"a.jsondsl:2:2",
+		"a.jsondsl:3:2",
+		"a.jsondsl:4:1",
+		"b.jsondsl:1:1",
+		"b.jsondsl:2:3",
+		"b.jsondsl:2:5",
+		"-",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestFileSetPosition(): got diff:\n%s", diff)
+	}
+}
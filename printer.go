@@ -0,0 +1,300 @@
+package jsondsl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// TrailingComma sets the policy used by the printer to lay out lists.
+type TrailingComma int
+
+const (
+	// TrailingCommaAuto prints lists whose last element has a trailing comma with
+	// one element per line, and all other lists on a single line.
+	TrailingCommaAuto TrailingComma = iota
+	// TrailingCommaAlways prints every non-empty list with one element per line.
+	TrailingCommaAlways
+	// TrailingCommaNever prints every list on a single line.
+	TrailingCommaNever
+)
+
+// PrintOptions control the output of Fprint and Format.
+//
+// Lists containing comments are always printed with one element per line
+// regardless of the TrailingComma policy. Lists printed with one element per
+// line always have a trailing comma.
+type PrintOptions struct {
+	Indent        string // Indent used per level of nesting; defaults to a tab.
+	TrailingComma TrailingComma
+}
+
+// Fprint pretty-prints a node to w.
+// A nil opts uses the default options.
+func Fprint(w io.Writer, node Node, opts *PrintOptions) error {
+	p := newPrinter(opts)
+	if err := p.printNode(node); err != nil {
+		return err
+	}
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// Format parses src and returns its canonically formatted source.
+// Comments are preserved and at most one blank line is kept between top-level nodes.
+// A nil opts uses the default options.
+func Format(src []byte, opts *PrintOptions) ([]byte, error) {
+	fset := NewFileSet()
+	nodes, err := ParseFile(fset, "", string(src), 0)
+	if err != nil {
+		return nil, err
+	}
+	p := newPrinter(opts)
+	if err := p.printFile(fset.File(0), nodes); err != nil {
+		return nil, err
+	}
+	return p.buf.Bytes(), nil
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	opts   PrintOptions
+	indent int
+}
+
+func newPrinter(opts *PrintOptions) *printer {
+	p := &printer{}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Indent == "" {
+		p.opts.Indent = "\t"
+	}
+	return p
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	for i := 0; i < p.indent; i++ {
+		p.buf.WriteString(p.opts.Indent)
+	}
+}
+
+// printFile prints the top-level nodes of f, each ending with a newline.
+func (p *printer) printFile(f *File, nodes []Node) error {
+	endLine := 0
+	for i, n := range nodes {
+		line := f.Line(n.Pos())
+		if i > 0 {
+			if _, ok := n.(*CommentGroup); ok && line == endLine {
+				p.buf.WriteByte(' ')
+			} else {
+				p.buf.WriteByte('\n')
+				if line > endLine+1 {
+					p.buf.WriteByte('\n')
+				}
+			}
+		}
+		if err := p.printNode(n); err != nil {
+			return err
+		}
+		endLine = f.Line(nodeEnd(n))
+	}
+	if len(nodes) > 0 {
+		p.buf.WriteByte('\n')
+	}
+	return nil
+}
+
+func (p *printer) printNode(n Node) error {
+	switch n := n.(type) {
+	case *Null:
+		p.buf.WriteString("null")
+	case *Bool:
+		if n.Literal {
+			p.buf.WriteString("true")
+		} else {
+			p.buf.WriteString("false")
+		}
+	case *Number:
+		p.buf.WriteString(n.Literal)
+	case *String:
+		p.buf.WriteString(n.QuotedContent)
+	case *Ident:
+		p.buf.WriteString(n.Name)
+	case *Array:
+		return printList(p, "[", n.Elements, n.Dangling, "]", func(v Value) error { return p.printNode(v) })
+	case *Object:
+		return printList(p, "{", n.Members, n.Dangling, "}", func(m *Member) error { return p.printNode(m) })
+	case *Member:
+		if err := p.printNode(n.Key); err != nil {
+			return err
+		}
+		p.buf.WriteString(": ")
+		return p.printNode(n.Value)
+	case *Operator:
+		if err := p.printNode(n.Id); err != nil {
+			return err
+		}
+		for _, args := range n.Args {
+			if err := printList(p, "(", args.ValueList, args.Dangling, ")", func(v Value) error { return p.printNode(v) }); err != nil {
+				return err
+			}
+		}
+	case *Selector:
+		if err := p.printNode(n.X); err != nil {
+			return err
+		}
+		p.buf.WriteByte('.')
+		return p.printNode(n.Sel)
+	case *Index:
+		if err := p.printNode(n.X); err != nil {
+			return err
+		}
+		p.buf.WriteByte('[')
+		if err := p.printNode(n.Index); err != nil {
+			return err
+		}
+		p.buf.WriteByte(']')
+	case *Slice:
+		if err := p.printNode(n.X); err != nil {
+			return err
+		}
+		p.buf.WriteByte('[')
+		if n.Low != nil {
+			if err := p.printNode(n.Low); err != nil {
+				return err
+			}
+		}
+		p.buf.WriteByte(':')
+		if n.High != nil {
+			if err := p.printNode(n.High); err != nil {
+				return err
+			}
+		}
+		p.buf.WriteByte(']')
+	case *CommentGroup:
+		p.printComments(n)
+	default:
+		return fmt.Errorf("unexpected node type %T", n)
+	}
+	return nil
+}
+
+// printComments prints the comments of g on separate lines.
+// The caller is responsible for printing the newline after the last comment.
+func (p *printer) printComments(g *CommentGroup) {
+	for i, c := range g.List {
+		if i > 0 {
+			p.newline()
+		}
+		p.buf.WriteString(c.Text)
+	}
+}
+
+// printList prints a list of elements as seen in the object, array, and operator specs.
+func printList[E Node](p *printer, open string, elems []ListElem[E], dangling *CommentGroup, close string, printFn func(E) error) error {
+	p.buf.WriteString(open)
+	if !multiline(p.opts.TrailingComma, elems, dangling) {
+		for i, e := range elems {
+			if i > 0 {
+				p.buf.WriteString(", ")
+			}
+			if err := printFn(e.Value); err != nil {
+				return err
+			}
+		}
+		p.buf.WriteString(close)
+		return nil
+	}
+	p.indent++
+	for _, e := range elems {
+		if e.Doc != nil {
+			p.newline()
+			p.printComments(e.Doc)
+		}
+		p.newline()
+		if err := printFn(e.Value); err != nil {
+			return err
+		}
+		p.buf.WriteByte(',')
+		if e.Comment != nil {
+			p.buf.WriteByte(' ')
+			p.printComments(e.Comment)
+		}
+	}
+	if dangling != nil {
+		p.newline()
+		p.printComments(dangling)
+	}
+	p.indent--
+	p.newline()
+	p.buf.WriteString(close)
+	return nil
+}
+
+// multiline reports whether the list should be printed with one element per line.
+func multiline[E Node](policy TrailingComma, elems []ListElem[E], dangling *CommentGroup) bool {
+	if dangling != nil {
+		return true
+	}
+	for _, e := range elems {
+		if e.Doc != nil || e.Comment != nil {
+			return true
+		}
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	switch policy {
+	case TrailingCommaAlways:
+		return true
+	case TrailingCommaNever:
+		return false
+	default:
+		return elems[len(elems)-1].Comma > 0
+	}
+}
+
+// nodeEnd returns the Pos of the last character of n.
+func nodeEnd(n Node) Pos {
+	switch n := n.(type) {
+	case *Null:
+		return n.NullPos + Pos(len("null")) - 1
+	case *Bool:
+		if n.Literal {
+			return n.LitPos + Pos(len("true")) - 1
+		}
+		return n.LitPos + Pos(len("false")) - 1
+	case *Number:
+		return n.LitPos + Pos(len(n.Literal)) - 1
+	case *String:
+		return n.Quote + Pos(len(n.QuotedContent)) - 1
+	case *Ident:
+		return n.NamePos + Pos(len(n.Name)) - 1
+	case *Array:
+		return n.RBrack
+	case *Object:
+		return n.RBrace
+	case *Member:
+		return nodeEnd(n.Value)
+	case *Operator:
+		if len(n.Args) == 0 {
+			return nodeEnd(n.Id)
+		}
+		return n.Args[len(n.Args)-1].RParen
+	case *Selector:
+		return nodeEnd(n.Sel)
+	case *Index:
+		return n.RBrack
+	case *Slice:
+		return n.RBrack
+	case *CommentGroup:
+		c := n.List[len(n.List)-1]
+		return c.Slash + Pos(len(c.Text)) - 1
+	case *Comment:
+		return n.Slash + Pos(len(n.Text)) - 1
+	default:
+		return n.Pos()
+	}
+}
@@ -0,0 +1,77 @@
+package jsondsl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	input := `// Bindings.
+bind(x,   [1,2,3])   // inline
+bind( y, { "a" :1, "b":[ null,true ], } )
+
+
+lambda(a,b)(  1 , /* two */ 2)
+config . db.f( 1 )
+xs[ 0 ]  xs[ 1 : ] xs[:2]
+`
+
+	got, err := Format([]byte(input), nil)
+
+	wantErr := false
+	want := `// Bindings.
+bind(x, [1, 2, 3]) // inline
+bind(y, {
+	"a": 1,
+	"b": [null, true],
+})
+
+lambda(a, b)(
+	1,
+	/* two */
+	2,
+)
+config.db.f(1)
+xs[0]
+xs[1:]
+xs[:2]
+`
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestFormat(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("TestFormat(): got diff:\n%s", diff)
+	}
+}
+
+func TestFormatTrailingCommaAlways(t *testing.T) {
+	input := `op([], {a: b}, [1, [2]])`
+
+	got, err := Format([]byte(input), &PrintOptions{Indent: "  ", TrailingComma: TrailingCommaAlways})
+
+	wantErr := false
+	want := `op(
+  [],
+  {
+    a: b,
+  },
+  [
+    1,
+    [
+      2,
+    ],
+  ],
+)
+`
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestFormat(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("TestFormat(): got diff:\n%s", diff)
+	}
+}
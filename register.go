@@ -0,0 +1,116 @@
+package jsondsl
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	scopeType   = reflect.TypeOf((*Scope)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterFunc binds the Go function fn in scope as a strict op named name.
+//
+// The op converts its evaluated arguments to the parameter types of fn as described
+// by UnmarshalValue and checks that the number of arguments matches, allowing any
+// number of trailing arguments for a variadic fn. The parameters of fn may begin with
+// a context.Context, followed by a *Scope, which receive the context of the evaluation
+// and the caller's scope rather than arguments.
+//
+// fn may return no results, a value, an error, or a value and an error. The value is
+// converted as described by MarshalValue, except for ops which are returned as is.
+func RegisterFunc(scope *Scope, name string, fn any) error {
+	if scope.Frozen() {
+		return newError(KindEval, NoPos, "cannot register %q in frozen scope", name)
+	}
+	op, err := funcOp(name, fn)
+	if err != nil {
+		return err
+	}
+	scope.BindStrict(name, op)
+	return nil
+}
+
+// funcOp returns a strict op calling fn.
+func funcOp(name string, fn any) (StrictFunc, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, fmt.Errorf("RegisterFunc %s: expected func, found %T", name, fn)
+	}
+	ft := fv.Type()
+
+	// Leading context and scope parameters.
+	in := 0
+	hasCtx := in < ft.NumIn() && ft.In(in) == contextType
+	if hasCtx {
+		in++
+	}
+	hasScope := in < ft.NumIn() && ft.In(in) == scopeType
+	if hasScope {
+		in++
+	}
+	params := make([]reflect.Type, ft.NumIn()-in)
+	for i := range params {
+		params[i] = ft.In(in + i)
+	}
+	variadic := ft.IsVariadic()
+
+	hasErr := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	switch {
+	case ft.NumOut() > 2, ft.NumOut() == 2 && !hasErr:
+		return nil, fmt.Errorf("RegisterFunc %s: expected func returning at most a value and an error, found %s", name, ft)
+	}
+	hasVal := ft.NumOut() == 2 || ft.NumOut() == 1 && !hasErr
+
+	return func(scope *Scope, args []any) (any, error) {
+		if variadic {
+			if err := checkMinArity(name, args, len(params)-1); err != nil {
+				return nil, err
+			}
+		} else if err := checkArity(name, args, len(params)); err != nil {
+			return nil, err
+		}
+		vals := make([]reflect.Value, 0, in+len(args))
+		if hasCtx {
+			vals = append(vals, reflect.ValueOf(scope.Context()))
+		}
+		if hasScope {
+			vals = append(vals, reflect.ValueOf(scope))
+		}
+		for i, a := range args {
+			t := params[min(i, len(params)-1)]
+			if variadic && i >= len(params)-1 {
+				t = t.Elem()
+			}
+			v := reflect.New(t).Elem()
+			if err := unmarshalValue(a, v); err != nil {
+				return nil, &Error{Kind: KindType, Pos: NoPos, Msg: fmt.Sprintf("%s expects %s in argument %d: %v", name, t, i, err), Err: err}
+			}
+			vals = append(vals, v)
+		}
+		out := fv.Call(vals)
+		if hasErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				if _, ok := err.(*Error); ok {
+					return nil, err
+				}
+				return nil, &Error{Kind: KindEval, Pos: NoPos, Msg: fmt.Sprintf("%s: %v", name, err), Err: err}
+			}
+		}
+		if !hasVal {
+			return nil, nil
+		}
+		switch v := out[0].Interface().(type) {
+		case OpFunc, StrictFunc:
+			return v, nil
+		}
+		v, err := marshalValue(out[0])
+		if err != nil {
+			return nil, newError(KindType, NoPos, "%s returned %v", name, err)
+		}
+		return v, nil
+	}, nil
+}
@@ -0,0 +1,94 @@
+package jsondsl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	scope := BuiltinScope()
+	for name, fn := range map[string]any{
+		"repeat": strings.Repeat,
+		"sum": func(xs ...int) int {
+			n := 0
+			for _, x := range xs {
+				n += x
+			}
+			return n
+		},
+		"label": func(ctx context.Context, s *Scope, prefix string, ids ...uint8) ([]string, error) {
+			if ctx == nil || s == nil {
+				return nil, fmt.Errorf("missing context or scope")
+			}
+			res := make([]string, len(ids))
+			for i, id := range ids {
+				res[i] = fmt.Sprint(prefix, id)
+			}
+			return res, nil
+		},
+		"point": func(p struct{ X, Y float64 }) map[string]float64 {
+			return map[string]float64{"x": p.X, "y": p.Y}
+		},
+		"noop": func() {},
+		"fail": func() error { return fmt.Errorf("failed") },
+	} {
+		if err := RegisterFunc(scope, name, fn); err != nil {
+			t.Fatalf("TestRegisterFunc(): failed to register %s: %v", name, err)
+		}
+	}
+	src := `[repeat("ab", 2), sum(), sum(1, 2, 3), label("id", 1, 2), point({"X": 1, "y": 2}), noop()]`
+
+	got, err := EvalSource(scope, src)
+
+	wantErr := false
+	want := []any{
+		"abab",
+		float64(0),
+		float64(6),
+		[]any{"id1", "id2"},
+		map[any]any{"x": float64(1), "y": float64(2)},
+		nil,
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestRegisterFunc(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestRegisterFunc(): got diff:\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		src  string
+		kind ErrorKind
+		msg  string
+	}{
+		{`repeat("a")`, KindArity, "repeat expects 2 arguments: got 1"},
+		{`repeat("a", 1.5)`, KindType, "repeat expects int in argument 1: number 1.5 overflows Go value of type int"},
+		{`label()`, KindArity, "label expects at least 1 arguments: got 0"},
+		{`label("a", 256)`, KindType, "label expects uint8 in argument 1: number 256 overflows Go value of type uint8"},
+		{`fail()`, KindEval, "fail: failed"},
+	} {
+		_, err := EvalSource(scope, tc.src)
+		var got *Error
+		if !errors.As(err, &got) {
+			t.Errorf("TestRegisterFunc(%q): got err = %v, want *Error", tc.src, err)
+			continue
+		}
+		if got.Kind != tc.kind || got.Msg != tc.msg {
+			t.Errorf("TestRegisterFunc(%q): got err = %q (kind %v), want %q (kind %v)", tc.src, got.Msg, got.Kind, tc.msg, tc.kind)
+		}
+	}
+
+	if err := RegisterFunc(scope, "bad", 1); err == nil {
+		t.Errorf("TestRegisterFunc(): got err = nil for non-func, want err")
+	}
+	if err := RegisterFunc(scope, "bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("TestRegisterFunc(): got err = nil for func with two values, want err")
+	}
+}
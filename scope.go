@@ -1,23 +1,44 @@
 package jsondsl
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
+// A Scope binds names to values.
+//
+// A Scope is not safe for concurrent use unless it is frozen. A frozen Scope and its
+// parents are never modified, so a prelude may be frozen once and shared between
+// goroutines, each evaluating in its own LocalScope of the prelude.
 type Scope struct {
 	Parent *Scope
 	Vars   map[string]any
+
+	state  *evalState // Resources used by the current evaluation.
+	files  *FileSet   // Files of the modules imported in s or nil.
+	frozen bool       // Whether Vars may no longer be modified.
 }
 
 func BuiltinScope() *Scope {
-	s := &Scope{Vars: make(map[string]any, len(builtinOps))}
+	s := &Scope{Vars: make(map[string]any, len(builtinForms)+len(builtinOps))}
+	for id, op := range builtinForms {
+		s.BindForm(id, op)
+	}
 	for id, op := range builtinOps {
-		s.Bind(id, op)
+		s.BindStrict(id, op)
 	}
 	return s
 }
 
+// Reset clears the bindings of s and sets its parent.
+// It panics if s is frozen.
 func (s *Scope) Reset(parent *Scope) {
+	if s.frozen {
+		panic("jsondsl: Reset of frozen Scope")
+	}
 	s.Parent = parent
 	s.Vars = make(map[string]any)
+	s.state = nil
 }
 
 func (s *Scope) Lookup(id string) (any, error) {
@@ -27,15 +48,105 @@ func (s *Scope) Lookup(id string) (any, error) {
 		}
 		s = s.Parent
 	}
-	return nil, fmt.Errorf("name %q not found", id)
+	return nil, newError(KindName, NoPos, "name %q not found", id)
 }
 
+// Bind binds id to val in s and returns the value it replaced if any.
+// It panics if s is frozen.
 func (s *Scope) Bind(id string, val any) (oldVal any, overwrote bool) {
+	if s.frozen {
+		panic(fmt.Sprintf("jsondsl: Bind of %q in frozen Scope", id))
+	}
 	oldVal, overwrote = s.Vars[id]
 	s.Vars[id] = val
 	return oldVal, overwrote
 }
 
+// BindStrict binds fn as an op whose arguments are evaluated in the caller's scope
+// before fn is called.
+func (s *Scope) BindStrict(id string, fn func(scope *Scope, args []any) (any, error)) (oldVal any, overwrote bool) {
+	return s.Bind(id, StrictFunc(fn))
+}
+
+// BindContext binds fn as an op whose arguments are evaluated in the caller's scope
+// before fn is called with the Context of the evaluation.
+func (s *Scope) BindContext(id string, fn func(ctx context.Context, scope *Scope, args []any) (any, error)) (oldVal any, overwrote bool) {
+	return s.BindStrict(id, func(scope *Scope, args []any) (any, error) {
+		return fn(scope.Context(), scope, args)
+	})
+}
+
+// BindForm binds fn as a special form which receives its arguments unevaluated.
+// Forms are responsible for evaluating their arguments as needed using Eval.
+func (s *Scope) BindForm(id string, fn OpFunc) (oldVal any, overwrote bool) {
+	return s.Bind(id, fn)
+}
+
+// LocalScope returns a new empty Scope whose parent is s.
+// The local scope is not frozen even if s is, so it is a cheap way to derive
+// a mutable scope from a shared prelude.
 func (s *Scope) LocalScope() *Scope {
-	return &Scope{Parent: s, Vars: make(map[string]any)}
+	return &Scope{Parent: s, Vars: make(map[string]any), state: s.state}
+}
+
+// Freeze freezes s and its parents and returns s.
+// Binding in a frozen Scope panics and the bind op returns an error.
+// Freeze must be called before s is shared between goroutines.
+func (s *Scope) Freeze() *Scope {
+	for p := s; p != nil && !p.frozen; p = p.Parent {
+		p.frozen = true
+	}
+	return s
+}
+
+// Frozen reports whether s is frozen.
+func (s *Scope) Frozen() bool {
+	return s.frozen
+}
+
+// Snapshot returns a frozen Scope without a parent binding the names visible in s
+// to their current values. Later changes to s and its parents do not affect the snapshot.
+func (s *Scope) Snapshot() *Scope {
+	vars := make(map[string]any)
+	for p := s; p != nil; p = p.Parent {
+		for id, v := range p.Vars {
+			if _, ok := vars[id]; !ok {
+				vars[id] = v
+			}
+		}
+	}
+	return &Scope{Vars: vars, files: s.fileSet(), frozen: true}
+}
+
+// withState returns a view of s sharing its bindings which tracks resources using state.
+func (s *Scope) withState(state *evalState) *Scope {
+	return &Scope{Parent: s.Parent, Vars: s.Vars, state: state, files: s.files, frozen: s.frozen}
+}
+
+// fileSet returns the files of the modules imported in s or its parents or nil.
+func (s *Scope) fileSet() *FileSet {
+	for ; s != nil; s = s.Parent {
+		if s.files != nil {
+			return s.files
+		}
+	}
+	return nil
+}
+
+// Context returns the Context of the evaluation in progress in s.
+// It returns the background context if the evaluation has no Context.
+// Ops that block should return when the Context is done.
+func (s *Scope) Context() context.Context {
+	return s.evalState().context()
+}
+
+// evalState returns the resources tracked for the evaluation in s or nil.
+// The state is set by the Evaluator on the scopes it passes to ops and is not
+// inherited from parents, so that ops defined during one evaluation, such as
+// lambdas, observe the limits of the evaluation calling them.
+func (s *Scope) evalState() *evalState {
+	if s == nil {
+		return nil
+	}
+	return s.state
 }
@@ -0,0 +1,122 @@
+package jsondsl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScopeFreeze(t *testing.T) {
+	prelude := BuiltinScope()
+	if _, err := EvalSource(prelude, `bind(double, lambda(x, mul(x, 2)))`); err != nil {
+		t.Fatalf("TestScopeFreeze(): got err = %v, want err = false", err)
+	}
+	local := prelude.LocalScope().Freeze().LocalScope()
+	if !prelude.Frozen() || local.Frozen() {
+		t.Fatalf("TestScopeFreeze(): got prelude frozen = %v, local frozen = %v, want true, false", prelude.Frozen(), local.Frozen())
+	}
+
+	var wg sync.WaitGroup
+	got := make([]any, 8)
+	errs := make([]error, len(got))
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scope := prelude.LocalScope()
+			scope.Bind("i", float64(i))
+			got[i], errs[i] = EvalSource(scope, `bind(y, double(i)) do(bind(z, 1), add(y, z))`)
+		}(i)
+	}
+	wg.Wait()
+
+	want := []any{float64(1), float64(3), float64(5), float64(7), float64(9), float64(11), float64(13), float64(15)}
+	if err := errors.Join(errs...); err != nil {
+		t.Fatalf("TestScopeFreeze(): got err = %v, want err = false", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestScopeFreeze(): got diff:\n%s", diff)
+	}
+
+	_, err := EvalSource(prelude, `bind(x, 1)`)
+	var gotErr *Error
+	if !errors.As(err, &gotErr) || gotErr.Kind != KindEval || gotErr.Msg != `cannot bind "x" in frozen scope` {
+		t.Errorf("TestScopeFreeze(): got err = %v, want frozen scope error", err)
+	}
+	if err := RegisterFunc(prelude, "f", func() {}); err == nil {
+		t.Errorf("TestScopeFreeze(): got RegisterFunc err = nil, want frozen scope error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("TestScopeFreeze(): got Bind in frozen scope did not panic")
+		}
+	}()
+	prelude.Bind("x", 1.0)
+}
+
+func TestScopeSnapshot(t *testing.T) {
+	parent := &Scope{Vars: map[string]any{"a": 1.0, "b": 2.0}}
+	s := parent.LocalScope()
+	s.Bind("b", 3.0)
+
+	snap := s.Snapshot()
+	s.Bind("a", 4.0)
+	parent.Bind("c", 5.0)
+
+	want := map[string]any{"a": 1.0, "b": 3.0}
+	if diff := cmp.Diff(want, snap.Vars); diff != "" {
+		t.Errorf("TestScopeSnapshot(): got diff:\n%s", diff)
+	}
+	if !snap.Frozen() || snap.Parent != nil {
+		t.Errorf("TestScopeSnapshot(): got frozen = %v, parent = %v, want frozen scope without parent", snap.Frozen(), snap.Parent)
+	}
+	if s.Frozen() {
+		t.Errorf("TestScopeSnapshot(): got source scope frozen, want not frozen")
+	}
+}
+
+func TestScopeFreezeConcurrent(t *testing.T) {
+	prelude := BuiltinScope()
+	if _, err := NewEvaluator(prelude, &EvalOptions{MaxSteps: 1000}).EvalSource(`bind(inc, lambda(x, add(x, 1)))`); err != nil {
+		t.Fatalf("TestScopeFreezeConcurrent(): got err = %v, want err = false", err)
+	}
+	prelude.Freeze()
+	p, err := Compile(prelude, decodeString(t, `reduce(range(n), lambda(a, x, inc(a)), 0)`))
+	if err != nil {
+		t.Fatalf("TestScopeFreezeConcurrent(): got err = %v, want err = false", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 16)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				v, err := EvalSource(prelude.LocalScope(), `reduce(range(100), lambda(a, x, inc(a)), 0)`)
+				if err == nil && v != float64(100) {
+					err = fmt.Errorf("EvalSource(): got %v, want 100", v)
+				}
+				if err == nil {
+					v, err = p.Run([]any{float64(100)})
+				}
+				if err == nil && v != float64(100) {
+					err = fmt.Errorf("Run(): got %v, want 100", v)
+				}
+				if err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Errorf("TestScopeFreezeConcurrent(): got err = %v, want err = false", err)
+	}
+}
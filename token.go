@@ -2,10 +2,13 @@ package jsondsl
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/wenooij/bufiog"
 )
 
 //go:generate stringer -type Token -trimprefix Token
@@ -28,6 +31,7 @@ const (
 	TokenNumber               // 123 -1.4e10
 	TokenIdent                // abc
 	TokenString               // "abc"
+	TokenComment              // // abc or /* abc */
 )
 
 var byteToken = map[byte]Token{
@@ -55,11 +59,42 @@ var tokenStr = map[Token]string{
 }
 
 type Tokenizer struct {
+	file      *File
 	advance   int
 	lastPos   Pos
 	lastToken Token
 }
 
+// SetFile sets the file used to compute token positions and record line starts.
+// Positions are plain byte offsets if no file is set.
+func (t *Tokenizer) SetFile(f *File) {
+	t.file = f
+}
+
+// File returns the file set with SetFile or nil.
+func (t *Tokenizer) File() *File {
+	return t.file
+}
+
+func (t *Tokenizer) pos(offset int) Pos {
+	if t.file != nil {
+		return t.file.Pos(offset)
+	}
+	return Pos(offset)
+}
+
+// addLines records line starts for all newlines in data which begins at the current offset.
+func (t *Tokenizer) addLines(data []byte) {
+	if t.file == nil {
+		return
+	}
+	for i, b := range data {
+		if b == '\n' {
+			t.file.AddLine(t.advance + i + 1)
+		}
+	}
+}
+
 func (t *Tokenizer) setToken(pos Pos, token Token) {
 	t.lastPos = pos
 	t.lastToken = token
@@ -95,10 +130,12 @@ func (t *Tokenizer) SplitFunc(data []byte, atEOF bool) (advance int, token []byt
 	var tok Token
 	defer func() {
 		if err != nil {
+			err = &Error{Kind: KindScan, Pos: t.pos(t.advance + begin), Msg: err.Error()}
 			t.setToken(NoPos, TokenInvalid)
 			return
 		}
-		t.setToken(Pos(t.advance+begin), tok)
+		t.setToken(t.pos(t.advance+begin), tok)
+		t.addLines(data[:advance])
 		t.advance += advance
 	}()
 
@@ -110,6 +147,39 @@ func (t *Tokenizer) SplitFunc(data []byte, atEOF bool) (advance int, token []byt
 		return advance + 1, data[advance : advance+1], nil
 	}
 
+	if data[advance] == '/' { // Comment
+		if len(data) < advance+2 {
+			if !atEOF {
+				return 0, nil, nil // Try again with a larger buffer if possible.
+			}
+			return 0, nil, fmt.Errorf("unexpected byte %q at start of token", data[advance])
+		}
+		switch data[advance+1] {
+		case '/':
+			i := bytes.IndexByte(data[advance+2:], '\n')
+			if i < 0 {
+				if !atEOF {
+					return 0, nil, nil // Try again with a larger buffer if possible.
+				}
+				i = len(data[advance+2:])
+			}
+			advance += 2 + i
+		case '*':
+			i := bytes.Index(data[advance+2:], []byte("*/"))
+			if i < 0 {
+				if !atEOF {
+					return 0, nil, nil // Try again with a larger buffer if possible.
+				}
+				return 0, nil, fmt.Errorf("comment not terminated")
+			}
+			advance += 2 + i + 2
+		default:
+			return 0, nil, fmt.Errorf("unexpected byte %q after '/' at start of token", data[advance+1])
+		}
+		tok = TokenComment
+		return advance, data[begin:advance], nil
+	}
+
 	switch {
 	case data[advance] == '-': // Number (negative).
 		advance++
@@ -121,6 +191,9 @@ func (t *Tokenizer) SplitFunc(data []byte, atEOF bool) (advance int, token []byt
 	loop:
 		for {
 			if len(data) <= advance {
+				if atEOF {
+					break loop
+				}
 				return 0, nil, nil // Try again with larger buffer if possible.
 			}
 			b := data[advance]
@@ -157,25 +230,33 @@ func (t *Tokenizer) SplitFunc(data []byte, atEOF bool) (advance int, token []byt
 		return advance, data[begin:advance], nil
 
 	case data[advance] == '"': // String
-		bs := data[advance+1:]
+		advance++
 		escape := false
-		for _, b := range bs {
-			advance++
-			if b == '\\' {
+		for ; advance < len(data); advance++ {
+			b := data[advance]
+			if escape {
+				escape = false
+			} else if b == '\\' {
 				escape = true
 			} else if b == '"' {
-				if !escape {
-					break
-				}
-				escape = false
+				break
 			}
 		}
+		if advance >= len(data) && !atEOF {
+			return 0, nil, nil // Try again with larger buffer if possible.
+		}
+		if advance >= len(data) {
+			advance = len(data) - 1 // Unterminated; unquoting reports the error.
+		}
 		tok = TokenString
 		return advance + 1, data[begin : advance+1], nil
 
 	case 'A' <= data[advance] && data[advance] <= 'Z' || 'a' <= data[advance] && data[advance] <= 'z' || data[advance] == '_': // Token
 		advance++
 		for {
+			if len(data) <= advance && !atEOF {
+				return 0, nil, nil // Try again with larger buffer if possible.
+			}
 			r, size := utf8.DecodeRune(data[advance:])
 			if !('A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '_') {
 				break
@@ -203,23 +284,57 @@ func (t *Tokenizer) SplitFunc(data []byte, atEOF bool) (advance int, token []byt
 type tokenReader struct {
 	t  *Tokenizer
 	sc *bufio.Scanner
+
+	// commentFn is called with every comment token and the Pos of the
+	// preceding non-comment token. Comments are never returned from Read.
+	commentFn func(c tokenPos, prev Pos)
+	last      Pos
+	end       Pos // End of the preceding non-comment token.
 }
 
 type tokenPos struct {
 	Text string
 	Token
 	Pos
+	Space bool // Space reports whether whitespace or comments separate the token from the preceding token.
+}
+
+// newTokenReader returns a buffered token reader over src.
+// If f is not nil, it is used for token positions and line starts.
+// Comments are passed to commentFn or skipped if commentFn is nil.
+func newTokenReader(f *File, src io.Reader, commentFn func(c tokenPos, prev Pos)) *bufiog.Reader[tokenPos] {
+	t := &Tokenizer{}
+	t.SetFile(f)
+	sc := bufio.NewScanner(src)
+	sc.Split(t.SplitFunc)
+	return bufiog.NewReaderSize(&tokenReader{
+		t:         t,
+		sc:        sc,
+		commentFn: commentFn,
+		last:      NoPos,
+		end:       NoPos,
+	}, 64)
 }
 
 func (r *tokenReader) Read(p []tokenPos) (n int, err error) {
-	for i := range p {
+	for n < len(p) {
 		if !r.sc.Scan() {
 			if err := r.sc.Err(); err != nil {
 				return n, err
 			}
 			return n, io.EOF // The caller might change this to UnexpectedEOF.
 		}
-		p[i] = tokenPos{Text: r.sc.Text(), Token: r.t.Token(), Pos: r.t.Pos()}
+		e := tokenPos{Text: r.sc.Text(), Token: r.t.Token(), Pos: r.t.Pos()}
+		if e.Token == TokenComment {
+			if r.commentFn != nil {
+				r.commentFn(e, r.last)
+			}
+			continue
+		}
+		e.Space = r.end != NoPos && r.end != e.Pos
+		r.end = e.Pos + Pos(len(e.Text))
+		r.last = e.Pos
+		p[n] = e
 		n++
 	}
 	return n, nil
@@ -24,11 +24,12 @@ func _() {
 	_ = x[TokenNumber-13]
 	_ = x[TokenIdent-14]
 	_ = x[TokenString-15]
+	_ = x[TokenComment-16]
 }
 
-const _Token_name = "InvalidColonCommaDotLParenRParenLBraceRBraceLBrackRBrackNullFalseTrueNumberIdentString"
+const _Token_name = "InvalidColonCommaDotLParenRParenLBraceRBraceLBrackRBrackNullFalseTrueNumberIdentStringComment"
 
-var _Token_index = [...]uint8{0, 7, 12, 17, 20, 26, 32, 38, 44, 50, 56, 60, 65, 69, 75, 80, 86}
+var _Token_index = [...]uint8{0, 7, 12, 17, 20, 26, 32, 38, 44, 50, 56, 60, 65, 69, 75, 80, 86, 93}
 
 func (i Token) String() string {
 	if i < 0 || i >= Token(len(_Token_index)-1) {
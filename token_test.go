@@ -85,6 +85,7 @@ func TestTokenize(t *testing.T) {
 		Token: TokenIdent,
 		Text:  "sum",
 		Pos:   16,
+		Space: true,
 	}, {
 		Token: TokenRParen,
 		Text:  ")",
@@ -11,7 +11,7 @@ func TypeName(v any) string {
 		return "number"
 	case string:
 		return "string"
-	case *Op:
+	case *Op, *SelectOp, *IndexOp, *SliceOp, OpFunc, StrictFunc:
 		return "op"
 	case []any:
 		return "array"
@@ -35,6 +35,16 @@ func AsBool(v any) bool {
 		return v != ""
 	case *Op:
 		return v != nil
+	case *SelectOp:
+		return v != nil
+	case *IndexOp:
+		return v != nil
+	case *SliceOp:
+		return v != nil
+	case OpFunc:
+		return v != nil
+	case StrictFunc:
+		return v != nil
 	case []any:
 		return len(v) != 0
 	case map[any]any:
@@ -52,3 +62,49 @@ func IsNull(v any) bool {
 		return false
 	}
 }
+
+// Equal reports whether the values a and b are deeply equal.
+// Ops are equal only if both are nil *Op values.
+func Equal(a, b any) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case float64:
+		b, ok := b.(float64)
+		return ok && a == b
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[any]any:
+		b, ok := b.(map[any]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			bv, ok := b[k]
+			if !ok || !Equal(v, bv) {
+				return false
+			}
+		}
+		return true
+	case *Op:
+		b, ok := b.(*Op)
+		return ok && a == nil && b == nil
+	default:
+		return false
+	}
+}
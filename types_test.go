@@ -0,0 +1,23 @@
+package jsondsl
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b any
+		want bool
+	}{
+		{nil, nil, true},
+		{float64(1), float64(1), true},
+		{"a", float64(1), false},
+		{[]any{"a", nil}, []any{"a", nil}, true},
+		{map[any]any{"a": []any{true}}, map[any]any{"a": []any{true}}, true},
+		{(*Op)(nil), (*Op)(nil), true},
+		{(*Op)(nil), nil, false},
+		{&Op{Id: "a"}, &Op{Id: "a"}, false},
+	} {
+		if got := Equal(tc.a, tc.b); got != tc.want {
+			t.Errorf("TestEqual(%v, %v): got %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
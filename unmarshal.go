@@ -0,0 +1,414 @@
+package jsondsl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Unmarshal decodes the value in data and stores the result in the value pointed to by v.
+// It is an error for data to contain more than one value.
+// Unmarshal follows the conventions of encoding/json, using the "jsondsl" struct tag to
+// override field names. Use a Decoder with an Evaluator to evaluate values before storing them.
+func Unmarshal(data []byte, v any) error {
+	d := &Decoder{}
+	d.Reset(bytes.NewReader(data))
+	if err := d.DecodeInto(v); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	es, err := d.Peek(1)
+	if err != nil && err != io.EOF {
+		return resolveError(err, nil)
+	}
+	if len(es) > 0 {
+		return resolveError(syntaxError(es[0], "unexpected token %s after top-level value", es[0].Token), nil)
+	}
+	return nil
+}
+
+// SetEvaluator sets the Evaluator used by DecodeInto to evaluate decoded values.
+// Values are stored as decoded if e is nil.
+func (d *Decoder) SetEvaluator(e *Evaluator) {
+	d.eval = e
+}
+
+// DecodeInto decodes the next value, evaluates it with the Evaluator if one was set,
+// and stores the result in the value pointed to by v.
+// It returns EOF if no value exists.
+func (d *Decoder) DecodeInto(v any) error {
+	val, err := d.Decode()
+	if err != nil {
+		return err
+	}
+	if d.eval != nil {
+		if val, err = d.eval.Eval(val); err != nil {
+			return err
+		}
+	}
+	return UnmarshalValue(val, v)
+}
+
+// UnmarshalValue stores the decoded or evaluated value val in the value pointed to by v.
+//
+// Numbers may be stored in any numeric kind provided they fit, arrays in slices and arrays,
+// and objects in maps and structs. Struct fields are matched by their "jsondsl" tag name,
+// or the field name, preferring an exact match over a case-insensitive match.
+// Interface values receive val as is.
+func UnmarshalValue(val any, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("unmarshal into non-pointer or nil value %T", v)
+	}
+	return unmarshalValue(val, rv.Elem())
+}
+
+var opType = reflect.TypeOf((*Op)(nil))
+
+func unmarshalValue(val any, rv reflect.Value) error {
+	if rv.Kind() == reflect.Pointer {
+		if val == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.Type() == opType {
+			if op, ok := val.(*Op); ok {
+				rv.Set(reflect.ValueOf(op))
+				return nil
+			}
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshalValue(val, rv.Elem())
+	}
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		if val == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(reflect.ValueOf(val))
+		}
+		return nil
+	}
+	switch val := val.(type) {
+	case nil:
+		switch rv.Kind() {
+		case reflect.Interface, reflect.Map, reflect.Slice:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	case bool:
+		if rv.Kind() != reflect.Bool {
+			return unmarshalTypeError(val, rv.Type())
+		}
+		rv.SetBool(val)
+		return nil
+	case float64:
+		return unmarshalNumber(val, rv)
+	case string:
+		if rv.Kind() != reflect.String {
+			return unmarshalTypeError(val, rv.Type())
+		}
+		rv.SetString(val)
+		return nil
+	case []any:
+		return unmarshalArray(val, rv)
+	case map[any]any:
+		return unmarshalObject(val, rv)
+	default:
+		if v := reflect.ValueOf(val); v.Type().AssignableTo(rv.Type()) {
+			rv.Set(v)
+			return nil
+		}
+		return unmarshalTypeError(val, rv.Type())
+	}
+}
+
+func unmarshalTypeError(val any, t reflect.Type) error {
+	return fmt.Errorf("cannot unmarshal %s into Go value of type %s", TypeName(val), t)
+}
+
+func unmarshalNumber(val float64, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if rv.OverflowFloat(val) {
+			return fmt.Errorf("number %v overflows Go value of type %s", val, rv.Type())
+		}
+		rv.SetFloat(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val != math.Trunc(val) || val < math.MinInt64 || val >= math.MaxInt64 || rv.OverflowInt(int64(val)) {
+			return fmt.Errorf("number %v overflows Go value of type %s", val, rv.Type())
+		}
+		rv.SetInt(int64(val))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if val != math.Trunc(val) || val < 0 || val >= math.MaxUint64 || rv.OverflowUint(uint64(val)) {
+			return fmt.Errorf("number %v overflows Go value of type %s", val, rv.Type())
+		}
+		rv.SetUint(uint64(val))
+	default:
+		return unmarshalTypeError(val, rv.Type())
+	}
+	return nil
+}
+
+func unmarshalArray(val []any, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(rv.Type(), len(val), len(val))
+		for i, e := range val {
+			if err := unmarshalValue(e, s.Index(i)); err != nil {
+				return fmt.Errorf("%v at array index %d", err, i)
+			}
+		}
+		rv.Set(s)
+	case reflect.Array:
+		if len(val) > rv.Len() {
+			return fmt.Errorf("array of length %d overflows Go value of type %s", len(val), rv.Type())
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(val) {
+				rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				continue
+			}
+			if err := unmarshalValue(val[i], rv.Index(i)); err != nil {
+				return fmt.Errorf("%v at array index %d", err, i)
+			}
+		}
+	default:
+		return unmarshalTypeError(val, rv.Type())
+	}
+	return nil
+}
+
+func unmarshalObject(val map[any]any, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Map:
+		t := rv.Type()
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(t, len(val)))
+		}
+		for k, v := range val {
+			k = objectKey(k)
+			kv := reflect.New(t.Key()).Elem()
+			if err := unmarshalValue(k, kv); err != nil {
+				return fmt.Errorf("%v at object key %s", err, keyName(k))
+			}
+			ev := reflect.New(t.Elem()).Elem()
+			if err := unmarshalValue(v, ev); err != nil {
+				return fmt.Errorf("%v at object value %s", err, keyName(k))
+			}
+			rv.SetMapIndex(kv, ev)
+		}
+	case reflect.Struct:
+		fields := cachedFields(rv.Type())
+		for k, v := range val {
+			name, ok := objectKey(k).(string)
+			if !ok {
+				return fmt.Errorf("cannot unmarshal %s object key into Go struct field of %s", TypeName(k), rv.Type())
+			}
+			f := fields.byName(name)
+			if f == nil {
+				continue
+			}
+			fv, err := fieldByIndex(rv, f.index)
+			if err != nil {
+				return err
+			}
+			if err := unmarshalValue(v, fv); err != nil {
+				return fmt.Errorf("%v at object value %q", err, name)
+			}
+		}
+	default:
+		return unmarshalTypeError(val, rv.Type())
+	}
+	return nil
+}
+
+// objectKey returns the object key k, treating a bare identifier as its name.
+func objectKey(k any) any {
+	if op, ok := k.(*Op); ok && len(op.Args) == 0 {
+		return op.Id
+	}
+	return k
+}
+
+// keyName formats the object key k for error messages.
+func keyName(k any) string {
+	if s, ok := k.(string); ok {
+		return strconv.Quote(s)
+	}
+	return TypeName(k)
+}
+
+// fieldByIndex returns the nested field of rv, allocating embedded struct pointers as needed.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", rv.Type().Elem())
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, nil
+}
+
+// field describes a struct field for unmarshaling and marshaling.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+type structFields struct {
+	list  []field
+	exact map[string]*field
+}
+
+func (fs *structFields) byName(name string) *field {
+	if f, ok := fs.exact[name]; ok {
+		return f
+	}
+	for i := range fs.list {
+		if strings.EqualFold(fs.list[i].name, name) {
+			return &fs.list[i]
+		}
+	}
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+func cachedFields(t reflect.Type) *structFields {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.(*structFields)
+	}
+	fs, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return fs.(*structFields)
+}
+
+// typeFields returns the fields of struct type t, including promoted fields of embedded structs.
+// As in encoding/json, fields at shallower depths hide fields with the same name at deeper
+// depths, a tagged field is preferred among fields at the same depth, and fields which
+// remain ambiguous are dropped. Each embedded struct type is walked at its shallowest depth only.
+func typeFields(t reflect.Type) *structFields {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []field
+	var tagged []bool
+	byName := make(map[string][]int)
+	visited := make(map[reflect.Type]bool)
+	for next := []embedded{{typ: t}}; len(next) > 0; {
+		current := next
+		next = nil
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				tag := sf.Tag.Get("jsondsl")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				idx := append(e.index[:len(e.index):len(e.index)], i)
+				if sf.Anonymous && name == "" {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embedded{typ: ft, index: idx})
+						continue
+					}
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				f := field{name: sf.Name, index: idx}
+				if name != "" {
+					f.name = name
+				}
+				for opts != "" {
+					var opt string
+					opt, opts, _ = strings.Cut(opts, ",")
+					if opt == "omitempty" {
+						f.omitEmpty = true
+					}
+				}
+				byName[f.name] = append(byName[f.name], len(fields))
+				fields = append(fields, f)
+				tagged = append(tagged, name != "")
+			}
+		}
+		// Mark types after walking the whole depth so that a type embedded more than
+		// once at the same depth yields ambiguous fields.
+		for _, e := range current {
+			visited[e.typ] = true
+		}
+	}
+
+	fs := &structFields{exact: make(map[string]*field)}
+	for _, is := range byName {
+		if f, ok := dominantField(fields, tagged, is); ok {
+			fs.list = append(fs.list, f)
+		}
+	}
+	sort.Slice(fs.list, func(i, j int) bool {
+		a, b := fs.list[i].index, fs.list[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	for i := range fs.list {
+		fs.exact[fs.list[i].name] = &fs.list[i]
+	}
+	return fs
+}
+
+// dominantField returns the field hiding the others among the fields at indices is,
+// which share a name and are ordered by depth, or false if there is none.
+func dominantField(fields []field, tagged []bool, is []int) (field, bool) {
+	depth := len(fields[is[0]].index)
+	var dominant []int
+	for _, i := range is {
+		if len(fields[i].index) > depth {
+			break
+		}
+		dominant = append(dominant, i)
+	}
+	if len(dominant) == 1 {
+		return fields[dominant[0]], true
+	}
+	found := -1
+	for _, i := range dominant {
+		if tagged[i] {
+			if found >= 0 {
+				return field{}, false
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return field{}, false
+	}
+	return fields[found], true
+}
@@ -0,0 +1,204 @@
+package jsondsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testEmbedded struct {
+	Level int
+}
+
+type testConfig struct {
+	testEmbedded
+	Name    string             `jsondsl:"name"`
+	Timeout uint16             `jsondsl:"timeout,omitempty"`
+	Ratio   float32            `jsondsl:"ratio"`
+	Tags    []string           `jsondsl:"tags"`
+	Limits  map[string]int     `jsondsl:"limits"`
+	Next    *testConfig        `jsondsl:"next"`
+	Extra   any                `jsondsl:"extra"`
+	Ignored string             `jsondsl:"-"`
+	Pair    [2]bool            `jsondsl:"pair"`
+	Keys    map[float64]string `jsondsl:"keys"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	input := `{
+		"name": "a",
+		timeout: 30,
+		"ratio": 0.5,
+		"tags": ["x", "y"],
+		"limits": {"cpu": 2},
+		"next": {"name": "b", "next": null},
+		"extra": op(1),
+		"Ignored": "no",
+		"pair": [true],
+		"keys": {1: "one"},
+		"level": 3,
+	}`
+
+	var got testConfig
+	err := Unmarshal([]byte(input), &got)
+
+	wantErr := false
+	want := testConfig{
+		testEmbedded: testEmbedded{Level: 3},
+		Name:         "a",
+		Timeout:      30,
+		Ratio:        0.5,
+		Tags:         []string{"x", "y"},
+		Limits:       map[string]int{"cpu": 2},
+		Next:         &testConfig{Name: "b"},
+		Extra:        &Op{Id: "op", Args: [][]any{{float64(1)}}, Pos: 144},
+		Pair:         [2]bool{true, false},
+		Keys:         map[float64]string{1: "one"},
+	}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestUnmarshal(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(testConfig{})); diff != "" {
+		t.Errorf("TestUnmarshal(): got diff:\n%s", diff)
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	input := `{"timeout": -1}`
+
+	var got testConfig
+	err := Unmarshal([]byte(input), &got)
+
+	wantErr := true
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestUnmarshal(): got err = %v, want err = %v", err, wantErr)
+	}
+}
+
+func TestDecodeIntoEval(t *testing.T) {
+	input := `bind(x, 5) [x, lambda(y, y)(2)]`
+
+	d := &Decoder{}
+	d.Reset(strings.NewReader(input))
+	e := &Evaluator{}
+	e.Init()
+	d.SetEvaluator(e)
+
+	var ignore any
+	if err := d.DecodeInto(&ignore); err != nil {
+		t.Fatalf("TestDecodeInto(): failed to decode bind: %v", err)
+	}
+	var got []int
+	err := d.DecodeInto(&got)
+
+	wantErr := false
+	want := []int{5, 2}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestDecodeInto(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestDecodeInto(): got diff:\n%s", diff)
+	}
+}
+
+type testRecursive struct {
+	*testRecursive
+	Name string
+}
+
+type testLeft struct {
+	Name string
+	Left int
+}
+
+type testRight struct {
+	Name  string
+	Right int
+}
+
+type testTagged struct {
+	Name string `jsondsl:"Name"`
+}
+
+type testAmbiguous struct {
+	testLeft
+	testRight
+}
+
+type testDominant struct {
+	testAmbiguous
+	testTagged
+}
+
+func TestUnmarshalEmbedded(t *testing.T) {
+	var rec testRecursive
+	if err := Unmarshal([]byte(`{"Name": "a"}`), &rec); err != nil {
+		t.Fatalf("TestUnmarshalEmbedded(): got err = %v, want err = false", err)
+	}
+	if rec.Name != "a" {
+		t.Errorf("TestUnmarshalEmbedded(): got Name = %q, want %q", rec.Name, "a")
+	}
+
+	var amb testAmbiguous
+	if err := Unmarshal([]byte(`{"Name": "a", "Left": 1, "Right": 2}`), &amb); err != nil {
+		t.Fatalf("TestUnmarshalEmbedded(): got err = %v, want err = false", err)
+	}
+	want := testAmbiguous{testLeft{Left: 1}, testRight{Right: 2}}
+	if diff := cmp.Diff(want, amb, cmp.AllowUnexported(testAmbiguous{})); diff != "" {
+		t.Errorf("TestUnmarshalEmbedded(): got diff:\n%s", diff)
+	}
+
+	var dom testDominant
+	if err := Unmarshal([]byte(`{"Name": "a"}`), &dom); err != nil {
+		t.Fatalf("TestUnmarshalEmbedded(): got err = %v, want err = false", err)
+	}
+	if dom.testTagged.Name != "a" || dom.testLeft.Name != "" || dom.testRight.Name != "" {
+		t.Errorf("TestUnmarshalEmbedded(): got %+v, want Name set on testTagged only", dom)
+	}
+}
+
+func TestUnmarshalTrailingData(t *testing.T) {
+	var got int
+	err := Unmarshal([]byte(`1 2`), &got)
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("TestUnmarshalTrailingData(): got err = %v, want *Error", err)
+	}
+	if e.Kind != KindSyntax || e.Pos != 2 {
+		t.Errorf("TestUnmarshalTrailingData(): got err = %v (kind %v, pos %d), want syntax error at 2", err, e.Kind, e.Pos)
+	}
+	if err := Unmarshal([]byte("1 \n"), &got); err != nil {
+		t.Errorf("TestUnmarshalTrailingData(): got err = %v for trailing space, want err = false", err)
+	}
+}
+
+func TestUnmarshalMapIdentKeys(t *testing.T) {
+	var got map[string]int
+	err := Unmarshal([]byte(`{a: 1, "b": 2}`), &got)
+
+	wantErr := false
+	want := map[string]int{"a": 1, "b": 2}
+
+	gotErr := err != nil
+	if gotErr != wantErr {
+		t.Fatalf("TestUnmarshalMapIdentKeys(): got err = %v, want err = %v", err, wantErr)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestUnmarshalMapIdentKeys(): got diff:\n%s", diff)
+	}
+
+	var nums map[float64]int
+	err = Unmarshal([]byte(`{a: 1}`), &nums)
+	if err == nil || !strings.HasSuffix(err.Error(), ` at object key "a"`) {
+		t.Errorf("TestUnmarshalMapIdentKeys(): got err = %v, want error at object key \"a\"", err)
+	}
+}
@@ -1,8 +1,6 @@
 package jsondsl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
 
 	"github.com/wenooij/bufiog"
@@ -11,7 +9,8 @@ import (
 type Visitor struct {
 	*bufiog.Reader[tokenPos]
 
-	visitFn func(Pos, Token, string) error
+	visitFn  func(Pos, Token, string) error
+	comments []tokenPos // Pending comments in source order.
 }
 
 func (v *Visitor) SetVisitor(fn func(Pos, Token, string) error) {
@@ -40,13 +39,20 @@ func callVisitor3[E, E2, E3 any](fn func(E, E2, E3) error, e E, e2 E2, e3 E3) er
 }
 
 func (v *Visitor) Visit(rd io.Reader) error {
-	t := &Tokenizer{}
-	sc := bufio.NewScanner(rd)
-	sc.Split(t.SplitFunc)
-	v.Reader = bufiog.NewReaderSize(&tokenReader{
-		t:  t,
-		sc: sc,
-	}, 64)
+	return v.VisitFile(nil, rd)
+}
+
+// VisitFile is like Visit but uses f, if not nil, to record positions and line starts of rd.
+// Errors are returned as an *Error wrapping any error returned by the visitor function.
+func (v *Visitor) VisitFile(f *File, rd io.Reader) error {
+	return resolveError(v.visitFile(f, rd), f)
+}
+
+func (v *Visitor) visitFile(f *File, rd io.Reader) error {
+	v.comments = nil
+	v.Reader = newTokenReader(f, rd, func(c tokenPos, _ Pos) {
+		v.comments = append(v.comments, c)
+	})
 
 	for {
 		if _, err := v.Peek(1); err != nil {
@@ -62,24 +68,122 @@ func (v *Visitor) Visit(rd io.Reader) error {
 			return err
 		}
 	}
+	return v.visitComments(NoPos)
+}
+
+// visitComments visits the pending comments preceding the Pos before.
+// All pending comments are visited if before is NoPos.
+func (v *Visitor) visitComments(before Pos) error {
+	for len(v.comments) > 0 {
+		c := v.comments[0]
+		if before != NoPos && c.Pos >= before {
+			break
+		}
+		v.comments = v.comments[1:]
+		if err := callVisitor3(v.visitFn, c.Pos, TokenComment, c.Text); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// visit calls the visitor for the token after visiting any comments preceding it.
+func (v *Visitor) visit(pos Pos, t Token, text string) error {
+	if err := v.visitComments(pos); err != nil {
+		return err
+	}
+	return callVisitor3(v.visitFn, pos, t, text)
+}
+
 func (v *Visitor) visitToken(t Token) error {
 	e, err := v.ReadElem()
 	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
 		return err
 	}
 	if e.Token != t {
-		return fmt.Errorf("expected token %s (found %s)", t, e.Token)
+		return syntaxError(e, "expected token %s (found %s)", t, e.Token)
 	}
-	if err := callVisitor3(v.visitFn, e.Pos, t, tokenStr[t]); err != nil {
+	if err := v.visit(e.Pos, t, tokenStr[t]); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (v *Visitor) visitValue() error {
+	if err := v.visitOperand(); err != nil {
+		return err
+	}
+	return v.visitPostfix()
+}
+
+// visitPostfix visits any selectors, indices, and slices following a value.
+// An index or slice must immediately follow the value so that it is not confused with an array.
+func (v *Visitor) visitPostfix() error {
+	for {
+		es, err := v.Peek(1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		switch {
+		case len(es) == 0:
+			return nil
+		case es[0].Token == TokenDot:
+			if err := v.visitToken(TokenDot); err != nil {
+				return err
+			}
+			if err := v.visitOperator(); err != nil {
+				return wrapError(err, "in selector")
+			}
+		case es[0].Token == TokenLBrack && !es[0].Space:
+			if err := v.visitIndex(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// visitIndex visits an index or slice.
+func (v *Visitor) visitIndex() error {
+	if err := v.visitToken(TokenLBrack); err != nil {
+		return wrapError(err, "at start of index")
+	}
+	if !v.peekToken(TokenColon) {
+		if err := v.visitValue(); err != nil {
+			return wrapError(err, "in index")
+		}
+		if !v.peekToken(TokenColon) {
+			if err := v.visitToken(TokenRBrack); err != nil {
+				return wrapError(err, "at end of index")
+			}
+			return nil
+		}
+	}
+	if err := v.visitToken(TokenColon); err != nil {
+		return wrapError(err, "in slice")
+	}
+	if !v.peekToken(TokenRBrack) {
+		if err := v.visitValue(); err != nil {
+			return wrapError(err, "in slice")
+		}
+	}
+	if err := v.visitToken(TokenRBrack); err != nil {
+		return wrapError(err, "at end of slice")
+	}
+	return nil
+}
+
+// peekToken reports whether the next token is t.
+func (v *Visitor) peekToken(t Token) bool {
+	es, _ := v.Peek(1)
+	return len(es) > 0 && es[0].Token == t
+}
+
+func (v *Visitor) visitOperand() error {
 	es, err := v.Peek(1)
 	if err != nil {
 		if err == io.EOF {
@@ -89,9 +193,9 @@ func (v *Visitor) visitValue() error {
 	}
 	switch e := es[0]; e.Token {
 	case TokenInvalid:
-		return fmt.Errorf("invalid token returned during scan")
-	case TokenColon, TokenComma, TokenLParen, TokenRParen, TokenRBrace, TokenRBrack:
-		return fmt.Errorf("unexpected token %s at beginning of Value", e.Token)
+		return syntaxError(e, "invalid token returned during scan")
+	case TokenColon, TokenComma, TokenDot, TokenLParen, TokenRParen, TokenRBrace, TokenRBrack:
+		return syntaxError(e, "unexpected token %s at beginning of Value", e.Token)
 	case TokenLBrace:
 		if err := v.visitObject(); err != nil {
 			return err
@@ -104,36 +208,36 @@ func (v *Visitor) visitValue() error {
 		return nil
 	case TokenNull, TokenFalse, TokenTrue, TokenNumber, TokenString:
 		v.Discard(1)
-		return callVisitor3(v.visitFn, e.Pos, e.Token, e.Text)
+		return v.visit(e.Pos, e.Token, e.Text)
 	case TokenIdent:
 		return v.visitOperator()
 	default:
-		return fmt.Errorf("unknown token %s returned during scan", e.Token)
+		return syntaxError(e, "unknown token %s returned during scan", e.Token)
 	}
 }
 
 func (v *Visitor) visitArray() error {
 	if err := v.visitToken(TokenLBrack); err != nil {
-		return fmt.Errorf("%v at start of array", err)
+		return wrapError(err, "at start of array")
 	}
 	if err := visitList[Value](v, TokenRBrack, v.visitValue); err != nil {
-		return fmt.Errorf("%v in array", err)
+		return wrapError(err, "in array")
 	}
 	if err := v.visitToken(TokenRBrack); err != nil {
-		return fmt.Errorf("%v at end of array", err)
+		return wrapError(err, "at end of array")
 	}
 	return nil
 }
 
 func (v *Visitor) visitObject() error {
 	if err := v.visitToken(TokenLBrace); err != nil {
-		return fmt.Errorf("%v at beginning of object", err)
+		return wrapError(err, "at beginning of object")
 	}
 	if err := visitList[*Member](v, TokenRBrace, v.visitMember); err != nil {
-		return fmt.Errorf("%v in object", err)
+		return wrapError(err, "in object")
 	}
 	if err := v.visitToken(TokenRBrace); err != nil {
-		return fmt.Errorf("%v at end of object", err)
+		return wrapError(err, "at end of object")
 	}
 	return nil
 }
@@ -141,31 +245,33 @@ func (v *Visitor) visitObject() error {
 func (v *Visitor) visitIdent() error {
 	e, err := v.ReadElem()
 	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
 		return err
 	}
 	if e.Token != TokenIdent {
-		return fmt.Errorf("expected token %s (found %s)", TokenIdent, e.Token)
+		return syntaxError(e, "expected token %s (found %s)", TokenIdent, e.Token)
 	}
-	callVisitor3(v.visitFn, e.Pos, TokenIdent, e.Text)
-	return nil
+	return v.visit(e.Pos, TokenIdent, e.Text)
 }
 
 func (v *Visitor) visitMember() error {
 	if err := v.visitValue(); err != nil {
-		return fmt.Errorf("%v at member key", err)
+		return wrapError(err, "at member key")
 	}
 	if err := v.visitToken(TokenColon); err != nil {
-		return fmt.Errorf("%v in object member", err)
+		return wrapError(err, "in object member")
 	}
 	if err := v.visitValue(); err != nil {
-		return fmt.Errorf("%v at member Value", err)
+		return wrapError(err, "at member Value")
 	}
 	return nil
 }
 
 func (v *Visitor) visitOperator() error {
 	if err := v.visitIdent(); err != nil {
-		return fmt.Errorf("%v at start of operator", err)
+		return wrapError(err, "at start of operator")
 	}
 	for {
 		es, err := v.Peek(1)
@@ -184,13 +290,13 @@ func (v *Visitor) visitOperator() error {
 
 func (v *Visitor) visitOperatorArgs() error {
 	if err := v.visitToken(TokenLParen); err != nil {
-		return fmt.Errorf("%v at start of operator arguments", err)
+		return wrapError(err, "at start of operator arguments")
 	}
 	if err := visitList[Value](v, TokenRParen, v.visitValue); err != nil {
-		return fmt.Errorf("%v at operator arguments", err)
+		return wrapError(err, "at operator arguments")
 	}
 	if err := v.visitToken(TokenRParen); err != nil {
-		return fmt.Errorf("%v at end of operator", err)
+		return wrapError(err, "at end of operator")
 	}
 	return nil
 }
@@ -225,7 +331,7 @@ func visitList[E Node](v *Visitor, delim Token, visitFn func() error) error {
 		switch es[0].Token {
 		case TokenComma:
 			v.Discard(1)
-			if err := callVisitor3(v.visitFn, es[0].Pos, TokenComma, ","); err != nil {
+			if err := v.visit(es[0].Pos, TokenComma, ","); err != nil {
 				return err
 			}
 		case delim:
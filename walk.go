@@ -0,0 +1,115 @@
+package jsondsl
+
+import "fmt"
+
+// An ASTVisitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type ASTVisitor interface {
+	Visit(n Node) (w ASTVisitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling v.Visit(n);
+// n must not be nil. If the visitor w returned by v.Visit(n) is not nil,
+// Walk is invoked recursively with visitor w for each of the non-nil children
+// of n, followed by a call of w.Visit(nil).
+//
+// List elements are visited as *ListElem[Value] or *ListElem[*Member] nodes
+// whose children are the element's Doc, Value, and Comment.
+func Walk(v ASTVisitor, n Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+
+	switch n := n.(type) {
+	case *BadValue, *Null, *Bool, *Number, *String, *Ident, *Comment:
+		// Nothing to do.
+
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
+
+	case *Array:
+		walkList(v, n.Elements, n.Dangling)
+
+	case *Object:
+		walkList(v, n.Members, n.Dangling)
+
+	case *Member:
+		Walk(v, n.Key)
+		Walk(v, n.Value)
+
+	case *Operator:
+		Walk(v, n.Id)
+		for _, args := range n.Args {
+			Walk(v, args)
+		}
+
+	case *Selector:
+		Walk(v, n.X)
+		Walk(v, n.Sel)
+
+	case *Index:
+		Walk(v, n.X)
+		Walk(v, n.Index)
+
+	case *Slice:
+		Walk(v, n.X)
+		if n.Low != nil {
+			Walk(v, n.Low)
+		}
+		if n.High != nil {
+			Walk(v, n.High)
+		}
+
+	case *OperatorArgs:
+		walkList(v, n.ValueList, n.Dangling)
+
+	case *ListElem[Value]:
+		walkElem(v, n)
+
+	case *ListElem[*Member]:
+		walkElem(v, n)
+
+	default:
+		panic(fmt.Sprintf("jsondsl.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkList[E Node](v ASTVisitor, elems []ListElem[E], dangling *CommentGroup) {
+	for i := range elems {
+		Walk(v, &elems[i])
+	}
+	if dangling != nil {
+		Walk(v, dangling)
+	}
+}
+
+func walkElem[E Node](v ASTVisitor, e *ListElem[E]) {
+	if e.Doc != nil {
+		Walk(v, e.Doc)
+	}
+	Walk(v, e.Value)
+	if e.Comment != nil {
+		Walk(v, e.Comment)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(n Node) ASTVisitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling f(n);
+// n must not be nil. If f returns true, Inspect invokes f recursively for
+// each of the non-nil children of n, followed by a call of f(nil).
+func Inspect(n Node, f func(Node) bool) {
+	Walk(inspector(f), n)
+}
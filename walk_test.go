@@ -0,0 +1,54 @@
+package jsondsl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInspect(t *testing.T) {
+	input := `{"a": [1, // one
+	x]}
+	op(true)()`
+
+	nodes, err := Parse(input)
+	if err != nil {
+		t.Fatalf("TestInspect(): failed to parse input: %v", err)
+	}
+
+	var got []string
+	for _, n := range nodes {
+		Inspect(n, func(n Node) bool {
+			if n != nil {
+				got = append(got, fmt.Sprintf("%T@%d", n, n.Pos()))
+			}
+			return true
+		})
+	}
+
+	want := []string{
+		"*jsondsl.Object@0",
+		"*jsondsl.ListElem[*github.com/wenooij/jsondsl.Member]@1",
+		"*jsondsl.Member@1",
+		"*jsondsl.String@1",
+		"*jsondsl.Array@6",
+		"*jsondsl.ListElem[github.com/wenooij/jsondsl.Value]@7",
+		"*jsondsl.Number@7",
+		"*jsondsl.CommentGroup@10",
+		"*jsondsl.Comment@10",
+		"*jsondsl.ListElem[github.com/wenooij/jsondsl.Value]@18",
+		"*jsondsl.Operator@18",
+		"*jsondsl.Ident@18",
+		"*jsondsl.Operator@23",
+		"*jsondsl.Ident@23",
+		"*jsondsl.OperatorArgs@25",
+		"*jsondsl.ListElem[github.com/wenooij/jsondsl.Value]@26",
+		"*jsondsl.Bool@26",
+		"*jsondsl.OperatorArgs@31",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestInspect(): got diff:\n%s", diff)
+	}
+}